@@ -0,0 +1,299 @@
+package versionedTerraform
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// constraintOperators lists the supported operators, longer operators first so
+// that ">=" is never mistaken for ">"
+var constraintOperators = []string{
+	latestPatch,
+	latestRelease,
+	versionLessOrEqual,
+	versionNotEqual,
+	versionGreaterThan,
+	versionLessThan,
+	versionEqual,
+}
+
+// Constraint is a single clause of a version constraint, i.e. ">= 0.12.0"
+type Constraint struct {
+	operator string
+	version  SemVersion
+}
+
+// Constraints is a set of clauses which must all be satisfied, i.e. ">= 0.12.0, < 0.14.0"
+type Constraints []Constraint
+
+// ParseConstraint returns a Constraint and an error if c is not a single valid clause
+// a clause without an operator is treated as an exact version
+func ParseConstraint(c string) (Constraint, error) {
+	return parseConstraintAt(c, c, 0)
+}
+
+// ParseConstraints splits a comma separated constraint string into its clauses
+// the position of an invalid token is reported relative to the whole string
+func ParseConstraints(c string) (Constraints, error) {
+	var constraints Constraints
+	offset := 0
+	for _, clause := range strings.Split(c, constraintSeparator) {
+		constraint, err := parseConstraintAt(c, clause, offset)
+		if err != nil {
+			return nil, err
+		}
+		constraints = append(constraints, constraint)
+		offset += len(clause) + len(constraintSeparator)
+	}
+	return constraints, nil
+}
+
+// parseConstraintAt parses clause which starts at offset within input
+func parseConstraintAt(input string, clause string, offset int) (Constraint, error) {
+	trimmed := strings.TrimLeft(clause, " ")
+	offset += len(clause) - len(trimmed)
+	operator := versionEqual
+	for _, op := range constraintOperators {
+		if strings.HasPrefix(trimmed, op) {
+			operator = op
+			trimmed = strings.TrimPrefix(trimmed, op)
+			offset += len(op)
+			break
+		}
+	}
+
+	version, err := parseSemVersionAt(constraintKind, input, trimmed, offset)
+	if err != nil {
+		return Constraint{}, err
+	}
+	return Constraint{operator: operator, version: version}, nil
+}
+
+// String returns the clause as it would be written in required_version
+func (c Constraint) String() string {
+	return c.operator + " " + c.version.ToString()
+}
+
+// Check returns true if s satisfies the clause
+// a pre-release only satisfies a clause naming a pre-release of the same major.minor.patch
+// version, matching the rules terraform applies to required_version
+func (c Constraint) Check(s SemVersion) bool {
+	if !c.isPreReleaseAllowed(s) {
+		return false
+	}
+	switch c.operator {
+	case versionEqual:
+		return s.IsEqualTo(c.version)
+	case versionNotEqual:
+		return !s.IsEqualTo(c.version)
+	case versionGreaterThan:
+		return s.IsGreaterThan(c.version)
+	case latestRelease:
+		return s.IsGreaterOrEqual(c.version)
+	case versionLessThan:
+		return s.IsLessThan(c.version)
+	case versionLessOrEqual:
+		return s.IsLessOrEqual(c.version)
+	case latestPatch:
+		return s.IsGreaterOrEqual(c.version) && c.isWithinPessimisticBound(s)
+	}
+	return false
+}
+
+// isPreReleaseAllowed returns true if the pre-release label of s, or lack of one, may
+// satisfy the clause. "~>" additionally never matches a release with a pre-release
+// clause or a pre-release with a release clause
+func (c Constraint) isPreReleaseAllowed(s SemVersion) bool {
+	switch {
+	case s.preRelease != "" && c.version.preRelease != "":
+		return s.majorVersion == c.version.majorVersion &&
+			s.minorVersion == c.version.minorVersion &&
+			s.patchVersion == c.version.patchVersion
+	case s.preRelease != "":
+		return false
+	case c.version.preRelease != "":
+		return c.operator != latestPatch
+	}
+	return true
+}
+
+// isWithinPessimisticBound returns true if s does not exceed the upper bound of a
+// "~>" clause, only the right-most written component of the clause may increase
+// i.e. "~> 1.2" allows 1.x while "~> 1.2.0" allows 1.2.x
+func (c Constraint) isWithinPessimisticBound(s SemVersion) bool {
+	switch c.version.segments {
+	case 1:
+		return true
+	case 2:
+		return s.majorVersion == c.version.majorVersion
+	}
+	return s.majorVersion == c.version.majorVersion &&
+		s.minorVersion == c.version.minorVersion
+}
+
+// String returns the clauses as a comma separated constraint string
+func (c Constraints) String() string {
+	var clauses []string
+	for _, constraint := range c {
+		clauses = append(clauses, constraint.String())
+	}
+	return strings.Join(clauses, constraintSeparator+" ")
+}
+
+// Check returns true if s satisfies every clause
+func (c Constraints) Check(s SemVersion) bool {
+	for _, constraint := range c {
+		if !constraint.Check(s) {
+			return false
+		}
+	}
+	return true
+}
+
+// Intersect returns the clauses of both c and other, a version satisfies the
+// result only if it satisfies both. Duplicate clauses are only included once
+func (c Constraints) Intersect(other Constraints) Constraints {
+	var intersection Constraints
+	for _, constraint := range append(append(Constraints{}, c...), other...) {
+		isDuplicate := false
+		for _, existing := range intersection {
+			if existing.String() == constraint.String() {
+				isDuplicate = true
+				break
+			}
+		}
+		if !isDuplicate {
+			intersection = append(intersection, constraint)
+		}
+	}
+	return intersection
+}
+
+// Matrix returns the newest version of every major.minor release in available which
+// satisfies every clause, sorted from oldest to newest
+func (c Constraints) Matrix(available []SemVersion) []SemVersion {
+	var matrix []SemVersion
+	for _, release := range available {
+		if !c.Check(release) {
+			continue
+		}
+		isNewRelease := true
+		for i, existing := range matrix {
+			if existing.majorVersion == release.majorVersion && existing.minorVersion == release.minorVersion {
+				isNewRelease = false
+				if release.IsGreaterThan(existing) {
+					matrix[i] = release
+				}
+				break
+			}
+		}
+		if isNewRelease {
+			matrix = append(matrix, release)
+		}
+	}
+
+	sort.Slice(matrix, func(i, j int) bool {
+		return matrix[i].IsLessThan(matrix[j])
+	})
+	return matrix
+}
+
+// UnsatisfiableError is returned when no available version satisfies a constraint
+// Below and Above are the closest available versions outside of the constraint, nil if there are none
+// StableFiltered is true when a pre-release would have satisfied the constraint if StableOnly was false
+type UnsatisfiableError struct {
+	Constraints    Constraints
+	Below          *SemVersion
+	Above          *SemVersion
+	StableFiltered bool
+}
+
+func (e *UnsatisfiableError) Error() string {
+	below, above := "none", "none"
+	if e.Below != nil {
+		below = e.Below.ToString()
+	}
+	if e.Above != nil {
+		above = e.Above.ToString()
+	}
+	message := fmt.Sprintf("no available terraform version satisfies %q (closest below: %s, closest above: %s)",
+		e.Constraints.String(), below, above)
+	if e.StableFiltered {
+		message += ", pre-release versions which satisfy it were skipped because StableOnly is true"
+	}
+	return message
+}
+
+// Select returns the newest version from available which satisfies every clause
+// returns an UnsatisfiableError if no version does
+func (c Constraints) Select(available []SemVersion) (SemVersion, error) {
+	return c.selectBy(available, func(release SemVersion, selected SemVersion) bool {
+		return release.IsGreaterThan(selected)
+	})
+}
+
+// SelectOldest returns the oldest version from available which satisfies every clause
+// returns an UnsatisfiableError if no version does
+func (c Constraints) SelectOldest(available []SemVersion) (SemVersion, error) {
+	return c.selectBy(available, func(release SemVersion, selected SemVersion) bool {
+		return release.IsLessThan(selected)
+	})
+}
+
+// selectBy returns the satisfying version from available which is preferred over all others
+// isPreferred returns true if release should replace the current selection
+func (c Constraints) selectBy(available []SemVersion, isPreferred func(release SemVersion, selected SemVersion) bool) (SemVersion, error) {
+	var selected SemVersion
+	isFound := false
+	for _, release := range available {
+		if !c.Check(release) {
+			continue
+		}
+		if !isFound || isPreferred(release, selected) {
+			selected = release
+			isFound = true
+		}
+	}
+
+	if !isFound {
+		return SemVersion{}, c.unsatisfiableError(available)
+	}
+	return selected, nil
+}
+
+// unsatisfiableError returns an UnsatisfiableError with the closest versions in available
+// at or below the lowest and at or above the highest version named in c
+func (c Constraints) unsatisfiableError(available []SemVersion) *UnsatisfiableError {
+	err := &UnsatisfiableError{Constraints: c}
+	if len(c) == 0 {
+		return err
+	}
+
+	lowest, highest := c[0].version, c[0].version
+	for _, constraint := range c {
+		if constraint.version.IsLessThan(lowest) {
+			lowest = constraint.version
+		}
+		if constraint.version.IsGreaterThan(highest) {
+			highest = constraint.version
+		}
+	}
+
+	for i := range available {
+		release := available[i]
+		if release.IsLessOrEqual(lowest) && (err.Below == nil || release.IsGreaterThan(*err.Below)) {
+			err.Below = &release
+		}
+	}
+	for i := range available {
+		release := available[i]
+		if err.Below != nil && release.IsEqualTo(*err.Below) {
+			continue
+		}
+		if release.IsGreaterOrEqual(highest) && (err.Above == nil || release.IsLessThan(*err.Above)) {
+			err.Above = &release
+		}
+	}
+	return err
+}
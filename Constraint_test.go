@@ -0,0 +1,260 @@
+package versionedTerraform
+
+import "testing"
+
+func TestParseConstraints(t *testing.T) {
+	cases := []struct {
+		constraint string
+		operators  []string
+		versions   []string
+	}{
+		{"1.2.3", []string{"="}, []string{"1.2.3"}},
+		{"v1.2.3", []string{"="}, []string{"1.2.3"}},
+		{"= 1.2.3", []string{"="}, []string{"1.2.3"}},
+		{"!=1.2.3", []string{"!="}, []string{"1.2.3"}},
+		{"> 1.2.3", []string{">"}, []string{"1.2.3"}},
+		{">= 1.2.3", []string{">="}, []string{"1.2.3"}},
+		{"< 1.2.3", []string{"<"}, []string{"1.2.3"}},
+		{"<= 1.2.3", []string{"<="}, []string{"1.2.3"}},
+		{"~> 1.2.3", []string{"~>"}, []string{"1.2.3"}},
+		{">= 0.12, < v0.14.0", []string{">=", "<"}, []string{"0.12", "0.14.0"}},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run("test parse constraint: "+c.constraint, func(t *testing.T) {
+			t.Parallel()
+			got, err := ParseConstraints(c.constraint)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(c.operators) {
+				t.Fatalf("got %d clauses, want %d", len(got), len(c.operators))
+			}
+			for i, clause := range got {
+				if clause.operator != c.operators[i] {
+					t.Errorf("got operator %q, want %q", clause.operator, c.operators[i])
+				}
+				if clause.version.ToString() != c.versions[i] {
+					t.Errorf("got version %q, want %q", clause.version.ToString(), c.versions[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseConstraint_invalid(t *testing.T) {
+	cases := []struct {
+		constraint, want string
+	}{
+		{"", `invalid version constraint "": missing version number at position 1`},
+		{">=", `invalid version constraint ">=": missing version number at position 3`},
+		{"~> 1.x", `invalid version constraint "~> 1.x": unexpected "x" at position 6`},
+		{"=> 1.0", `invalid version constraint "=> 1.0": unexpected ">" at position 2`},
+		{"1.2.3.4", `invalid version constraint "1.2.3.4": unexpected ".4" at position 6`},
+		{"1..3", `invalid version constraint "1..3": missing version number at position 3`},
+		{"1.2.3-beta_1", `invalid version constraint "1.2.3-beta_1": unexpected "_" at position 11`},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run("test parse invalid constraint: "+c.constraint, func(t *testing.T) {
+			t.Parallel()
+			_, err := ParseConstraint(c.constraint)
+			if err == nil {
+				t.Fatalf("expected an error for %q", c.constraint)
+			}
+			if err.Error() != c.want {
+				t.Errorf("got %q, want %q", err.Error(), c.want)
+			}
+		})
+	}
+}
+
+func TestConstraintCheck(t *testing.T) {
+	cases := []struct {
+		constraint, version string
+		want                bool
+	}{
+		{"= 1.2.3", "1.2.3", true},
+		{"= 1.2.3", "1.2.4", false},
+		{"!= 1.2.3", "1.2.3", false},
+		{"!= 1.2.3", "1.2.4", true},
+		{"> 1.2.3", "1.2.3", false},
+		{"> 1.2.3", "1.3.0", true},
+		{">= 1.2.3", "1.2.3", true},
+		{">= 1.2.3", "1.2.2", false},
+		{"< 1.2.3", "1.2.2", true},
+		{"< 1.2.3", "1.2.3", false},
+		{"<= 1.2.3", "1.2.3", true},
+		{"<= 1.2.3", "1.2.4", false},
+		{"~> 1.2.3", "1.2.9", true},
+		{"~> 1.2.3", "1.2.2", false},
+		{"~> 1.2.3", "1.3.0", false},
+		{"~> 1.2.0", "1.2.9", true},
+		{"~> 1.2.0", "1.3.0", false},
+		{"~> 1.2", "1.5.0", true},
+		{"~> 1.2", "1.1.9", false},
+		{"~> 1.2", "2.0.0", false},
+		{"~> 1", "3.0.0", true},
+		{"~> 1", "0.15.0", false},
+		{">= 1.0.0, < 1.3.0", "1.2.9", true},
+		{">= 1.0.0, < 1.3.0", "1.3.0", false},
+		{">= 1.0.0, < 1.3.0", "0.15.5", false},
+		{">= 1.5.0", "1.6.0-rc1", false},
+		{">= 1.6.0-beta1", "1.6.0-rc1", true},
+		{">= 1.6.0-beta1", "1.6.0-alpha1", false},
+		{">= 1.6.0-beta1", "1.6.0", true},
+		{">= 1.6.0-beta1", "1.7.0-alpha1", false},
+		{"= 1.6.0-rc1", "1.6.0-rc1", true},
+		{"!= 1.6.0-rc1", "1.6.0-rc2", true},
+		{"!= 1.6.0-rc1", "1.6.0-rc1", false},
+		{"~> 1.6.0-beta1", "1.6.0-rc1", true},
+		{"~> 1.6.0-beta1", "1.6.0", false},
+		{"~> 1.6.0", "1.6.1-rc1", false},
+		{">= 1.6.0-beta1, < 2.0.0", "1.6.0-rc1", false},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run("test constraint check: "+c.constraint+" "+c.version, func(t *testing.T) {
+			t.Parallel()
+			constraints, err := ParseConstraints(c.constraint)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := constraints.Check(*NewSemVersion(c.version))
+			if got != c.want {
+				t.Errorf("got %t, want %t", got, c.want)
+			}
+		})
+	}
+}
+
+func TestConstraints_String(t *testing.T) {
+	cases := []struct {
+		constraint, want string
+	}{
+		{"1.2.3", "= 1.2.3"},
+		{">=0.12,<v0.14.0", ">= 0.12, < 0.14.0"},
+		{"~> 1.2.0, != 1.2.5", "~> 1.2.0, != 1.2.5"},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run("test constraint string: "+c.constraint, func(t *testing.T) {
+			t.Parallel()
+			constraints, err := ParseConstraints(c.constraint)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := constraints.String(); got != c.want {
+				t.Errorf("got %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestConstraints_Intersect(t *testing.T) {
+	c1, _ := ParseConstraints(">= 0.12, < 1.0")
+	c2, _ := ParseConstraints("~> 0.13.0, < 1.0")
+
+	got := c1.Intersect(c2)
+	want := ">= 0.12, < 1.0, ~> 0.13.0"
+	if got.String() != want {
+		t.Errorf("got %q, want %q", got.String(), want)
+	}
+	if !got.Check(*NewSemVersion("0.13.1")) {
+		t.Errorf("expected 0.13.1 to satisfy %q", got.String())
+	}
+	if got.Check(*NewSemVersion("0.14.0")) {
+		t.Errorf("expected 0.14.0 to not satisfy %q", got.String())
+	}
+	if c1.String() != ">= 0.12, < 1.0" {
+		t.Errorf("expected Intersect to leave the receiver unchanged got %q", c1.String())
+	}
+}
+
+func TestConstraints_Select(t *testing.T) {
+	var available []SemVersion
+	for _, version := range testVersionList() {
+		available = append(available, *NewSemVersion(version))
+	}
+
+	cases := []struct {
+		constraint, want string
+	}{
+		{">= 0.12, < 0.14", "0.13.1"},
+		{"~> 1.0.0", "1.0.12"},
+		{">= 1.1.10", "1.1.11"},
+		{">= 1.2.23-alpha", "1.2.23-alpha"},
+		{">= 0.11, != 0.11.15, < 0.12", "0.11.10"},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run("test constraint select: "+c.constraint, func(t *testing.T) {
+			t.Parallel()
+			constraints, _ := ParseConstraints(c.constraint)
+			got, err := constraints.Select(available)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.ToString() != c.want {
+				t.Errorf("got %q, want %q", got.ToString(), c.want)
+			}
+		})
+	}
+
+	constraints, _ := ParseConstraints(">= 9.9.9")
+	if _, err := constraints.Select(available); err == nil {
+		t.Errorf("expected an error when no version satisfies %q", constraints.String())
+	}
+}
+
+func TestUnsatisfiableError_Error(t *testing.T) {
+	below := NewSemVersion("1.1.11")
+	constraints, _ := ParseConstraints("<= 9.9.9, > 5.0")
+	err := &UnsatisfiableError{Constraints: constraints, Below: below, StableFiltered: true}
+
+	want := `no available terraform version satisfies "<= 9.9.9, > 5.0" (closest below: 1.1.11, closest above: none)` +
+		", pre-release versions which satisfy it were skipped because StableOnly is true"
+	if err.Error() != want {
+		t.Errorf("got %q, want %q", err.Error(), want)
+	}
+}
+
+func TestConstraints_Matrix(t *testing.T) {
+	var available []SemVersion
+	for _, version := range testVersionList() {
+		available = append(available, *NewSemVersion(version))
+	}
+
+	cases := []struct {
+		constraint string
+		want       []string
+	}{
+		{">= 0.12, < 1.1", []string{"0.12.31", "0.13.1", "0.14.0", "1.0.12"}},
+		{"~> 1.1.0", []string{"1.1.11"}},
+		{">= 1.1", []string{"1.1.11"}},
+		{">= 1.1, != 1.2.23-beta", []string{"1.1.11"}},
+		{"> 5.0", []string{}},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run("test constraint matrix: "+c.constraint, func(t *testing.T) {
+			t.Parallel()
+			constraints, _ := ParseConstraints(c.constraint)
+			got := constraints.Matrix(available)
+			if len(got) != len(c.want) {
+				t.Fatalf("got %+v, want %v", got, c.want)
+			}
+			for i, version := range got {
+				if version.ToString() != c.want[i] {
+					t.Errorf("got %q at %d, want %q", version.ToString(), i, c.want[i])
+				}
+			}
+		})
+	}
+}
@@ -1,17 +1,28 @@
 package versionedTerraform
 
 import (
+	"fmt"
 	"strconv"
 	"strings"
 )
 
 const (
-	//todo include others if needed
-	//todo add comparison i.e. >= 0.11.10, < 0.12.0
-	latestRelease      = ">="
-	latestPatch        = "~>"
-	versionLessOrEqual = "<="
-	versionLessThan    = "<"
+	latestRelease       = ">="
+	latestPatch         = "~>"
+	versionLessOrEqual  = "<="
+	versionLessThan     = "<"
+	versionGreaterThan  = ">"
+	versionEqual        = "="
+	versionNotEqual     = "!="
+	constraintSeparator = ","
+	versionPrefix       = "v"
+
+	preReleaseSeparator    = "-"
+	buildMetadataSeparator = "+"
+	versionSeparator       = "."
+
+	versionKind    = "version"
+	constraintKind = "version constraint"
 )
 
 type SemVersion struct {
@@ -20,54 +31,191 @@ type SemVersion struct {
 	majorVersion int
 	minorVersion int
 	patchVersion int
+	segments     int
+	preRelease   string
+	metadata     string
 }
 
 type SemVersionInterface interface {
+	setMetadata()
+	setPreRelease()
 	setMajorVersion()
 	setMinorVersion()
 	setPatchVersion()
+	setSegments()
+}
+
+//ParseError describes the offending token of a version or constraint which could not be parsed
+//Position is the 1-based character offset of Token within Input
+type ParseError struct {
+	Kind     string
+	Input    string
+	Token    string
+	Position int
+	Reason   string
+}
+
+func (e *ParseError) Error() string {
+	if e.Token == "" {
+		return fmt.Sprintf("invalid %s %q: %s at position %d", e.Kind, e.Input, e.Reason, e.Position)
+	}
+	return fmt.Sprintf("invalid %s %q: %s %q at position %d", e.Kind, e.Input, e.Reason, e.Token, e.Position)
+}
+
+//ParseSemVersion returns a SemVersion and an error if v is not a valid version
+//a leading "v" is accepted and dropped, i.e. v1.2.3 is read as 1.2.3
+func ParseSemVersion(v string) (SemVersion, error) {
+	return parseSemVersionAt(versionKind, v, v, 0)
 }
 
+//parseSemVersionAt parses version which starts at offset within input
+//input and kind are only used to report the position of an invalid token
+func parseSemVersionAt(kind string, input string, version string, offset int) (SemVersion, error) {
+	position := offset + len(version) - len(strings.TrimLeft(version, " "))
+	version = strings.TrimSpace(version)
+	if version == "" {
+		return SemVersion{}, &ParseError{kind, input, "", position + 1, "missing version number"}
+	}
+	if strings.HasPrefix(version, versionPrefix) {
+		version = strings.TrimPrefix(version, versionPrefix)
+		position++
+	}
+
+	remaining := version
+	remaining, metadata, hasMetadata := cutString(remaining, buildMetadataSeparator)
+	core, preRelease, hasPreRelease := cutString(remaining, preReleaseSeparator)
+
+	for i, part := range strings.Split(core, versionSeparator) {
+		if i > 2 {
+			return SemVersion{}, &ParseError{kind, input, versionSeparator + part, position, "unexpected"}
+		}
+		if part == "" {
+			return SemVersion{}, &ParseError{kind, input, "", position + 1, "missing version number"}
+		}
+		for i, r := range part {
+			if r < '0' || r > '9' {
+				return SemVersion{}, &ParseError{kind, input, string(r), position + i + 1, "unexpected"}
+			}
+		}
+		position += len(part) + len(versionSeparator)
+	}
+
+	if hasPreRelease {
+		if err := validateIdentifiers(kind, input, preRelease, position); err != nil {
+			return SemVersion{}, err
+		}
+		position += len(preRelease) + len(preReleaseSeparator)
+	}
+
+	if hasMetadata {
+		if err := validateIdentifiers(kind, input, metadata, position); err != nil {
+			return SemVersion{}, err
+		}
+	}
+
+	return *NewSemVersion(version), nil
+}
+
+//validateIdentifiers checks the dot separated pre-release or build metadata identifiers
+//which start at offset within input
+func validateIdentifiers(kind string, input string, identifiers string, offset int) error {
+	position := offset
+	for _, identifier := range strings.Split(identifiers, versionSeparator) {
+		if identifier == "" {
+			return &ParseError{kind, input, "", position + 1, "missing identifier"}
+		}
+		for i, r := range identifier {
+			if !(r >= '0' && r <= '9') && !(r >= 'a' && r <= 'z') && !(r >= 'A' && r <= 'Z') && r != '-' {
+				return &ParseError{kind, input, string(r), position + i + 1, "unexpected"}
+			}
+		}
+		position += len(identifier) + len(versionSeparator)
+	}
+	return nil
+}
+
+//NewSemVersion returns a SemVersion pointer without validating v
+//invalid components are read as 0, use ParseSemVersion for input which may be invalid
 func NewSemVersion(v string) *SemVersion {
 	s := new(SemVersion)
 	s.isStable = true
 	s.version = removeSpacesVersion(v)
 
+	s.setMetadata()
+	s.setPreRelease()
 	s.setMajorVersion()
 	s.setMinorVersion()
 	s.setPatchVersion()
+	s.setSegments()
 	return s
 }
 
+//versionCore returns the major.minor.patch portion of SemVersion.version
+//without pre-release or build metadata
+func (s *SemVersion) versionCore() string {
+	core := strings.SplitN(s.version, buildMetadataSeparator, 2)[0]
+	return strings.SplitN(core, preReleaseSeparator, 2)[0]
+}
+
+//setMetadata setter for SemVersion.metadata, build metadata is ignored when comparing versions
+func (s *SemVersion) setMetadata() {
+	versionSlice := strings.SplitN(s.version, buildMetadataSeparator, 2)
+	if len(versionSlice) < 2 {
+		s.metadata = ""
+		return
+	}
+	s.metadata = versionSlice[1]
+}
+
+//setPreRelease setter for SemVersion.preRelease and SemVersion.isStable
+func (s *SemVersion) setPreRelease() {
+	version := strings.SplitN(s.version, buildMetadataSeparator, 2)[0]
+	versionSlice := strings.SplitN(version, preReleaseSeparator, 2)
+	if len(versionSlice) < 2 {
+		s.preRelease = ""
+		s.isStable = true
+		return
+	}
+	s.preRelease = versionSlice[1]
+	s.isStable = false
+}
+
 //setMajorVersion setter for SemVersion.majorVersion
 func (s *SemVersion) setMajorVersion() {
-	version := s.version
+	version := s.versionCore()
 	majorVersionString := strings.Split(version, ".")[0]
 	s.majorVersion, _ = strconv.Atoi(majorVersionString)
 }
 
 //setMinorVersion setter for SemVersion.minorVersion
 func (s *SemVersion) setMinorVersion() {
-	version := s.version
-	minorVersionString := strings.Split(version, ".")[1]
-	s.minorVersion, _ = strconv.Atoi(minorVersionString)
+	version := s.versionCore()
+	minorStringSlice := strings.Split(version, ".")
+	if len(minorStringSlice) < 2 {
+		s.minorVersion = 0
+		return
+	}
+	s.minorVersion, _ = strconv.Atoi(minorStringSlice[1])
 
 }
 
 //setPatchVersion setter for SemVersion.patchVersion
 func (s *SemVersion) setPatchVersion() {
-	version := s.version
-	var err error
+	version := s.versionCore()
 	patchStringSlice := strings.Split(version, ".")
 	if len(patchStringSlice) < 3 {
 		s.patchVersion = 0
 		return
 	}
-	s.patchVersion, err = strconv.Atoi(patchStringSlice[2])
-	if err != nil {
-		s.isStable = false
-		patchStringSlice = strings.Split(patchStringSlice[2], "-")
-		s.patchVersion, _ = strconv.Atoi(patchStringSlice[0])
+	s.patchVersion, _ = strconv.Atoi(patchStringSlice[2])
+}
+
+//setSegments setter for SemVersion.segments, the number of version components written
+//i.e. 1.2 has two segments while 1.2.0 has three
+func (s *SemVersion) setSegments() {
+	s.segments = len(strings.Split(s.versionCore(), "."))
+	if s.segments > 3 {
+		s.segments = 3
 	}
 }
 
@@ -88,7 +236,8 @@ func (s *SemVersion) VersionInSlice(sSem []SemVersion) bool {
 }
 
 func (s *SemVersion) IsEqualTo(s2 SemVersion) bool {
-	if s.majorVersion == s2.majorVersion && s.minorVersion == s2.minorVersion && s.patchVersion == s2.patchVersion {
+	if s.majorVersion == s2.majorVersion && s.minorVersion == s2.minorVersion && s.patchVersion == s2.patchVersion &&
+		comparePreRelease(s.preRelease, s2.preRelease) == 0 {
 		return true
 	}
 	return false
@@ -104,6 +253,9 @@ func (s *SemVersion) IsLessThan(s2 SemVersion) bool {
 	if s2.patchVersion > s.patchVersion && s2.majorVersion == s.majorVersion && s2.minorVersion == s.minorVersion {
 		return true
 	}
+	if s2.patchVersion == s.patchVersion && s2.majorVersion == s.majorVersion && s2.minorVersion == s.minorVersion {
+		return comparePreRelease(s.preRelease, s2.preRelease) < 0
+	}
 	return false
 }
 
@@ -117,6 +269,9 @@ func (s *SemVersion) IsGreaterThan(s2 SemVersion) bool {
 	if s2.patchVersion < s.patchVersion && s2.majorVersion == s.majorVersion && s2.minorVersion == s.minorVersion {
 		return true
 	}
+	if s2.patchVersion == s.patchVersion && s2.majorVersion == s.majorVersion && s2.minorVersion == s.minorVersion {
+		return comparePreRelease(s.preRelease, s2.preRelease) > 0
+	}
 	return false
 }
 
@@ -133,3 +288,83 @@ func (s *SemVersion) IsGreaterOrEqual(s2 SemVersion) bool {
 	}
 	return false
 }
+
+//comparePreRelease compares two pre-release labels following SemVer 2.0 precedence
+//returns -1 if p1 has lower precedence than p2, 1 if higher and 0 if equal
+//a release (empty label) has higher precedence than any pre-release of the same version
+func comparePreRelease(p1 string, p2 string) int {
+	if p1 == p2 {
+		return 0
+	}
+	if p1 == "" {
+		return 1
+	}
+	if p2 == "" {
+		return -1
+	}
+
+	identifiers1 := strings.Split(p1, ".")
+	identifiers2 := strings.Split(p2, ".")
+	for i := 0; i < len(identifiers1) && i < len(identifiers2); i++ {
+		if result := comparePreReleaseIdentifier(identifiers1[i], identifiers2[i]); result != 0 {
+			return result
+		}
+	}
+
+	switch {
+	case len(identifiers1) < len(identifiers2):
+		return -1
+	case len(identifiers1) > len(identifiers2):
+		return 1
+	}
+	return 0
+}
+
+//comparePreReleaseIdentifier compares a single dot separated pre-release identifier
+//numeric identifiers are compared numerically and always have lower precedence than alphanumeric ones
+//they are compared by length and then lexically so identifiers of any size are compared exactly
+func comparePreReleaseIdentifier(i1 string, i2 string) int {
+	isNumeric1 := isNumericIdentifier(i1)
+	isNumeric2 := isNumericIdentifier(i2)
+
+	switch {
+	case isNumeric1 && isNumeric2:
+		n1 := strings.TrimLeft(i1, "0")
+		n2 := strings.TrimLeft(i2, "0")
+		switch {
+		case len(n1) < len(n2):
+			return -1
+		case len(n1) > len(n2):
+			return 1
+		}
+		return strings.Compare(n1, n2)
+	case isNumeric1:
+		return -1
+	case isNumeric2:
+		return 1
+	}
+	return strings.Compare(i1, i2)
+}
+
+//isNumericIdentifier returns true if a pre-release identifier only contains ASCII digits
+//an identifier such as -1 contains a hyphen and is alphanumeric
+func isNumericIdentifier(identifier string) bool {
+	if identifier == "" {
+		return false
+	}
+	for _, c := range identifier {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+//cutString slices s around the first instance of sep
+//returns the text before and after sep and whether sep was found
+func cutString(s string, sep string) (string, string, bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
@@ -771,3 +771,137 @@ func TestSemVersion_IsGreaterOrEqual(t *testing.T) {
 		})
 	}
 }
+
+func TestNewSemVersion_segments(t *testing.T) {
+	cases := []struct {
+		version string
+		want    int
+	}{
+		{"1", 1},
+		{"1.2", 2},
+		{"1.2.3", 3},
+		{"1.2.3-beta1", 3},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run("Test: "+c.version, func(t *testing.T) {
+			t.Parallel()
+			got := NewSemVersion(c.version).segments
+			if c.want != got {
+				t.Errorf("Expected %d got %d", c.want, got)
+			}
+		})
+	}
+}
+
+func TestNewSemVersion_preRelease(t *testing.T) {
+	cases := []struct {
+		version, preRelease, metadata string
+		isStable                      bool
+		major, minor, patch           int
+	}{
+		{"1.6.0", "", "", true, 1, 6, 0},
+		{"1.6.0-alpha20230719", "alpha20230719", "", false, 1, 6, 0},
+		{"1.6.0-rc.1", "rc.1", "", false, 1, 6, 0},
+		{"1.6.0+build.5", "", "build.5", true, 1, 6, 0},
+		{"1.6.0-beta1+exp.sha-5114f85", "beta1", "exp.sha-5114f85", false, 1, 6, 0},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run("Test: "+c.version, func(t *testing.T) {
+			t.Parallel()
+			got := NewSemVersion(c.version)
+			if got.preRelease != c.preRelease || got.metadata != c.metadata || got.isStable != c.isStable {
+				t.Errorf("Expected %q %q %t got %q %q %t",
+					c.preRelease, c.metadata, c.isStable, got.preRelease, got.metadata, got.isStable)
+			}
+			if got.majorVersion != c.major || got.minorVersion != c.minor || got.patchVersion != c.patch {
+				t.Errorf("Expected %d.%d.%d got %d.%d.%d",
+					c.major, c.minor, c.patch, got.majorVersion, got.minorVersion, got.patchVersion)
+			}
+		})
+	}
+}
+
+func TestSemVersion_preReleaseOrdering(t *testing.T) {
+	// each version has lower precedence than the one following it
+	ordered := []string{
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.99999999999999999999",
+		"1.0.0-alpha.100000000000000000000",
+		"1.0.0-alpha.-1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0",
+		"1.6.0-alpha20230719",
+		"1.6.0-beta1",
+		"1.6.0-rc1",
+		"1.6.0",
+	}
+
+	for i := 0; i < len(ordered)-1; i++ {
+		lower := *NewSemVersion(ordered[i])
+		higher := *NewSemVersion(ordered[i+1])
+		t.Run("Test: "+lower.ToString()+" < "+higher.ToString(), func(t *testing.T) {
+			if !lower.IsLessThan(higher) {
+				t.Errorf("Expected %s to be less than %s", lower.ToString(), higher.ToString())
+			}
+			if !higher.IsGreaterThan(lower) {
+				t.Errorf("Expected %s to be greater than %s", higher.ToString(), lower.ToString())
+			}
+			if lower.IsEqualTo(higher) {
+				t.Errorf("Expected %s to not equal %s", lower.ToString(), higher.ToString())
+			}
+		})
+	}
+}
+
+func TestSemVersion_IsEqualTo_metadata(t *testing.T) {
+	s1 := NewSemVersion("1.6.0-rc1+build.1")
+	s2 := NewSemVersion("1.6.0-rc1+build.2")
+	if !s1.IsEqualTo(*s2) {
+		t.Errorf("Expected build metadata to be ignored when comparing versions")
+	}
+}
+
+func TestParseSemVersion(t *testing.T) {
+	cases := []struct {
+		version, want, err string
+	}{
+		{"1.2.3", "1.2.3", ""},
+		{"v1.2.3", "1.2.3", ""},
+		{" 1.2 ", "1.2", ""},
+		{"1.6.0-rc.1+build.5", "1.6.0-rc.1+build.5", ""},
+		{"", "", `invalid version "": missing version number at position 1`},
+		{"1.x", "", `invalid version "1.x": unexpected "x" at position 3`},
+		{"1.2.", "", `invalid version "1.2.": missing version number at position 5`},
+		{"1.2.3-", "", `invalid version "1.2.3-": missing identifier at position 7`},
+		{"1.2.3+build!", "", `invalid version "1.2.3+build!": unexpected "!" at position 12`},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run("Test: "+c.version, func(t *testing.T) {
+			t.Parallel()
+			got, err := ParseSemVersion(c.version)
+			if c.err != "" {
+				if err == nil || err.Error() != c.err {
+					t.Errorf("Expected error %q got %v", c.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.ToString() != c.want {
+				t.Errorf("Expected %q got %q", c.want, got.ToString())
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package versionedTerraform
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Strategy decides which of the versions satisfying a constraint is selected
+type Strategy string
+
+const (
+	// StrategyNewest selects the newest satisfying version
+	StrategyNewest Strategy = "newest"
+	// StrategyOldest selects the oldest satisfying version, useful to test declared lower bounds
+	StrategyOldest Strategy = "oldest"
+	// StrategyPreferInstalled selects the newest satisfying version which is already installed
+	// falling back to StrategyNewest if none is
+	StrategyPreferInstalled Strategy = "prefer-installed"
+)
+
+var (
+	resolutionStrategy = StrategyNewest
+	installedVersions  []SemVersion
+)
+
+// ParseStrategy returns the Strategy named by s and an error if s is not a known strategy
+func ParseStrategy(s string) (Strategy, error) {
+	switch strategy := Strategy(strings.ToLower(strings.TrimSpace(s))); strategy {
+	case StrategyNewest, StrategyOldest, StrategyPreferInstalled:
+		return strategy, nil
+	}
+	return StrategyNewest, fmt.Errorf("invalid strategy %q: expected %s, %s or %s",
+		s, StrategyNewest, StrategyOldest, StrategyPreferInstalled)
+}
+
+// SetResolutionStrategy sets the Strategy used by NewVersion, GetVersionFromFile and
+// GetVersionFromConstraint along with the versions already installed for StrategyPreferInstalled
+// resolving with Options ignores it
+func SetResolutionStrategy(strategy Strategy, installed []SemVersion) {
+	resolutionStrategy = strategy
+	installedVersions = installed
+}
+
+// Options decides how a version is resolved, unlike the package level setters each Options is independent
+// so several configurations may be resolved by the same program, the zero value selects the newest version
+type Options struct {
+	// Strategy selects one of the versions satisfying the constraint, StrategyNewest when empty
+	Strategy Strategy
+	// InstalledVersions are the versions already installed, selected first by StrategyPreferInstalled
+	InstalledVersions []SemVersion
+	// StableOnly excludes pre-releases
+	StableOnly bool
+	// PreferRequiredVersion gives required_version precedence over version files such as .terraform-version,
+	// which are then only used by directories without any required_version
+	PreferRequiredVersion bool
+	// SearchBoundary is the directory above which parent directories are not searched for version files
+	SearchBoundary string
+}
+
+// packageOptions returns the Options set by SetResolutionStrategy, SetPreferVersionFile and SetSearchBoundary
+func packageOptions(stableOnly bool) Options {
+	return Options{
+		Strategy:              resolutionStrategy,
+		InstalledVersions:     installedVersions,
+		StableOnly:            stableOnly,
+		PreferRequiredVersion: !preferVersionFile,
+		SearchBoundary:        searchBoundary,
+	}
+}
+
+// withStrategy returns a copy of o selecting versions with strategy
+func (o Options) withStrategy(strategy Strategy) Options {
+	o.Strategy = strategy
+	return o
+}
@@ -0,0 +1,64 @@
+package versionedTerraform
+
+import "testing"
+
+func TestParseStrategy(t *testing.T) {
+	cases := []struct {
+		value   string
+		want    Strategy
+		isValid bool
+	}{
+		{"newest", StrategyNewest, true},
+		{"oldest", StrategyOldest, true},
+		{"prefer-installed", StrategyPreferInstalled, true},
+		{" Oldest ", StrategyOldest, true},
+		{"latest", StrategyNewest, false},
+		{"", StrategyNewest, false},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run("test parse strategy: "+c.value, func(t *testing.T) {
+			t.Parallel()
+			got, err := ParseStrategy(c.value)
+			if (err == nil) != c.isValid {
+				t.Errorf("got error %v, want valid %t", err, c.isValid)
+			}
+			if got != c.want {
+				t.Errorf("got %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestGetVersion_strategy(t *testing.T) {
+	defer SetResolutionStrategy(resolutionStrategy, installedVersions)
+	defer func(value bool) { needsStable = value }(needsStable)
+	needsStable = true
+
+	installed := []SemVersion{*NewSemVersion("1.1.3"), *NewSemVersion("0.12.30"), *NewSemVersion("1.2.23-alpha")}
+	cases := []struct {
+		strategy          Strategy
+		version, expected string
+	}{
+		{StrategyNewest, "~> 1.1.0", "1.1.11"},
+		{StrategyOldest, "~> 1.1.0", "1.1.1"},
+		{StrategyOldest, ">= 0.12, < 0.14", "0.12.30"},
+		{StrategyPreferInstalled, "~> 1.1.0", "1.1.3"},
+		{StrategyPreferInstalled, "~> 0.13.0", "0.13.1"},
+		{StrategyPreferInstalled, ">= 1.1.4", "1.1.11"},
+	}
+
+	for _, c := range cases {
+		t.Run("test Version check with strategy "+string(c.strategy)+": "+c.version, func(t *testing.T) {
+			SetResolutionStrategy(c.strategy, installed)
+			got, err := NewVersion(c.version, testVersionList())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Version.version != c.expected {
+				t.Errorf("got %q, want %q", got.Version.version, c.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	wrapperFlagPrefix = "vt-"
+	chdirArgument     = "-chdir="
+)
+
+var (
+	wrapperFlags = flag.NewFlagSet("versionedTerraform", flag.ContinueOnError)
+	strategyFlag = wrapperFlags.String("vt-strategy", "", "version resolution strategy: newest, oldest or prefer-installed")
+	offlineFlag  = wrapperFlags.Bool("vt-offline", false, "only use installed terraform versions and never use the network")
+	helpFlag     = wrapperFlags.Bool("vt-help", false, "list the options and commands of versionedTerraform")
+)
+
+func init() {
+	wrapperFlags.Usage = func() {
+		printUsage(wrapperFlags.Output())
+	}
+}
+
+// splitArgs separates the options of versionedTerraform, --vt-<name> or -vt-<name>, from the
+// arguments for terraform which are returned verbatim and in their original order
+func splitArgs(flags *flag.FlagSet, args []string) ([]string, []string) {
+	var wrapperArgs, terraformArgs []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name := strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+		if name == arg || !strings.HasPrefix(name, wrapperFlagPrefix) {
+			terraformArgs = append(terraformArgs, arg)
+			continue
+		}
+
+		wrapperArgs = append(wrapperArgs, arg)
+		// the value of a non boolean option may be the next argument, i.e. --vt-strategy oldest
+		if !strings.Contains(name, "=") && i+1 < len(args) && takesValue(flags, name) {
+			i++
+			wrapperArgs = append(wrapperArgs, args[i])
+		}
+	}
+	return wrapperArgs, terraformArgs
+}
+
+// takesValue returns true if name is an option of flags which is not boolean
+func takesValue(flags *flag.FlagSet, name string) bool {
+	f := flags.Lookup(name)
+	if f == nil {
+		return false
+	}
+	boolFlag, isBoolFlag := f.Value.(interface{ IsBoolFlag() bool })
+	return !isBoolFlag || !boolFlag.IsBoolFlag()
+}
+
+// chdirDir returns the directory of terraform's -chdir=DIR option, or an empty string if it is not set
+// terraform only reads it before the subcommand
+func chdirDir(terraformArgs []string) string {
+	for _, arg := range terraformArgs {
+		if !strings.HasPrefix(arg, "-") {
+			break
+		}
+		if strings.HasPrefix(arg, chdirArgument) {
+			return strings.TrimPrefix(arg, chdirArgument)
+		}
+	}
+	return ""
+}
+
+// printUsage writes the options and commands of versionedTerraform
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: versionedTerraform [--vt-<option>...] [terraform arguments]\n"+
+		"       versionedTerraform %s <command>\n\n"+
+		"Arguments other than the options below are passed to terraform unchanged\n\nOptions:\n", wrapperCommand)
+	wrapperFlags.SetOutput(w)
+	wrapperFlags.PrintDefaults()
+	fmt.Fprintf(w, "\nCommands:\n%s", wrapperCommandUsage)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitArgs(t *testing.T) {
+	cases := []struct {
+		name                       string
+		args                       []string
+		wantWrapper, wantTerraform []string
+	}{
+		{"terraform arguments only",
+			[]string{"-chdir=envs/prod", "plan", "-var", "a=b"},
+			nil, []string{"-chdir=envs/prod", "plan", "-var", "a=b"}},
+		{"value in the next argument",
+			[]string{"--vt-strategy", "oldest", "plan"},
+			[]string{"--vt-strategy", "oldest"}, []string{"plan"}},
+		{"value after =",
+			[]string{"plan", "--vt-strategy=oldest", "-out", "plan.out"},
+			[]string{"--vt-strategy=oldest"}, []string{"plan", "-out", "plan.out"}},
+		{"boolean option does not take the next argument",
+			[]string{"--vt-offline", "apply"},
+			[]string{"--vt-offline"}, []string{"apply"}},
+		{"boolean option with a value",
+			[]string{"--vt-offline=false", "apply"},
+			[]string{"--vt-offline=false"}, []string{"apply"}},
+		{"single dash option",
+			[]string{"-vt-strategy", "newest", "-vt-help"},
+			[]string{"-vt-strategy", "newest", "-vt-help"}, nil},
+		{"unknown option does not take the next argument",
+			[]string{"--vt-unknown", "plan"},
+			[]string{"--vt-unknown"}, []string{"plan"}},
+		{"option missing its value",
+			[]string{"plan", "--vt-strategy"},
+			[]string{"--vt-strategy"}, []string{"plan"}},
+		{"terraform options resembling wrapper options",
+			[]string{"plan", "-var=vt-strategy=oldest", "vt-offline"},
+			nil, []string{"plan", "-var=vt-strategy=oldest", "vt-offline"}},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run("Test: "+c.name, func(t *testing.T) {
+			gotWrapper, gotTerraform := splitArgs(wrapperFlags, c.args)
+			if !reflect.DeepEqual(gotWrapper, c.wantWrapper) {
+				t.Errorf("Expected wrapper arguments %q, got %q", c.wantWrapper, gotWrapper)
+			}
+			if !reflect.DeepEqual(gotTerraform, c.wantTerraform) {
+				t.Errorf("Expected terraform arguments %q, got %q", c.wantTerraform, gotTerraform)
+			}
+		})
+	}
+}
+
+func TestTakesValue(t *testing.T) {
+	cases := []struct {
+		name string
+		want bool
+	}{
+		{"vt-strategy", true},
+		{"vt-offline", false},
+		{"vt-help", false},
+		{"vt-unknown", false},
+	}
+
+	for _, c := range cases {
+		if got := takesValue(wrapperFlags, c.name); got != c.want {
+			t.Errorf("takesValue(%q) expected %t got %t", c.name, c.want, got)
+		}
+	}
+}
+
+func TestChdirDir(t *testing.T) {
+	cases := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"before the subcommand", []string{"-chdir=envs/prod", "plan"}, "envs/prod"},
+		{"after other global options", []string{"-no-color", "-chdir=envs/prod", "plan"}, "envs/prod"},
+		{"after the subcommand", []string{"plan", "-chdir=envs/prod"}, ""},
+		{"not set", []string{"plan", "-out", "plan.out"}, ""},
+		{"no arguments", nil, ""},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run("Test: "+c.name, func(t *testing.T) {
+			if got := chdirDir(c.args); got != c.want {
+				t.Errorf("Expected %q, got %q", c.want, got)
+			}
+		})
+	}
+}
@@ -1,10 +1,13 @@
 package main
 
 import (
-	"flag"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
 	"versionedTerraform"
 )
 
@@ -13,16 +16,53 @@ const (
 	shortConfigDirString = "/.versionedTerraform"
 	pwd                  = "."
 	terraformPrefix      = "/terraform_"
+	wrapperCommand       = "vt"
+	wrapperCommandUsage  = "  resolve [--explain] [DIR|CONSTRAINT]  print the terraform version which would be executed\n" +
+		"  matrix [DIR|CONSTRAINT]               print the newest patch of every satisfying minor release as JSON\n"
+)
+
+const (
+	strategyEnv         = "VERSIONEDTERRAFORM_STRATEGY"
+	searchBoundaryEnv   = "VERSIONEDTERRAFORM_SEARCH_BOUNDARY"
+	mirrorUrlEnv        = "VERSIONEDTERRAFORM_MIRROR_URL"
+	indexUrlEnv         = "VERSIONEDTERRAFORM_INDEX_URL"
+	downloadTemplateEnv = "VERSIONEDTERRAFORM_DOWNLOAD_URL_TEMPLATE"
+	checksumTemplateEnv = "VERSIONEDTERRAFORM_CHECKSUM_URL_TEMPLATE"
+	offlineEnv          = "VERSIONEDTERRAFORM_OFFLINE"
 )
 
 var needsStable = true
 
 func main() {
+	// Options of versionedTerraform are separated from the arguments passed to terraform
+	wrapperArgs, args := splitArgs(wrapperFlags, os.Args[1:])
+	if err := wrapperFlags.Parse(wrapperArgs); err != nil {
+		os.Exit(2)
+	}
+	if *helpFlag {
+		printUsage(os.Stdout)
+		os.Exit(0)
+	}
+
 	homeDir, _ := os.UserHomeDir()
 	configDirString := homeDir + shortConfigDirString
 
+	// Select the mirror before the version list may be fetched while creating the configuration
+	if err := setReleaseUrls(configDirString + "/" + configFileLocation); err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to determine release mirror: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Never use the network in offline mode, not even to create the configuration
+	offline, err := offlineMode(configDirString + "/" + configFileLocation)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to determine offline mode: %v\n", err)
+		os.Exit(1)
+	}
+	versionedTerraform.SetOffline(offline)
+
 	// Create configuration directory if it does not exist
-	_, err := os.Stat(configDirString)
+	_, err = os.Stat(configDirString)
 	if os.IsNotExist(err) {
 		err = versionedTerraform.CreateConfig(configDirString, configFileLocation)
 	}
@@ -39,22 +79,16 @@ func main() {
 	}
 
 	configDir := os.DirFS(configDirString)
-	workingDir := os.DirFS(pwd)
 	var versionsFromConfig []versionedTerraform.SemVersion
 
-	flag.Parse()
-	args := flag.Args()
-
-	//Load available versions from configuration file
-	versionsFromConfig, err = versionedTerraform.LoadVersionsFromConfig(configDir, configFileLocation)
-	if err != nil {
-		fmt.Printf("Unable to read config: %v\n", err)
-		os.Exit(1)
+	// -chdir is read to resolve the version from the same directory as terraform
+	workingDir := pwd
+	if chdir := chdirDir(args); chdir != "" {
+		workingDir = chdir
 	}
 
 	//Check if we need to update available versions with terraform's website
 	//Then update configuration if we do
-	//todo move this above loading the config
 	needsUpdate, err := versionedTerraform.NeedToUpdateAvailableVersions(configDir, configFileLocation)
 	if os.ErrNotExist == err {
 		fmt.Printf("Unable to update version: %v\n", err)
@@ -62,10 +96,33 @@ func main() {
 
 	fileHandle, _ := os.OpenFile(configDirString+"/"+configFileLocation, os.O_RDWR, 0666)
 	defer fileHandle.Close()
+	var updateErr error
 	if needsUpdate {
-		versionedTerraform.UpdateConfig(*fileHandle)
+		updateErr = versionedTerraform.UpdateConfig(*fileHandle)
+		if errors.Is(updateErr, versionedTerraform.ErrOffline) {
+			updateErr = nil
+		}
+	}
+
+	//Load available versions from configuration file
+	versionsFromConfig, err = versionedTerraform.LoadVersionsFromConfig(configDir, configFileLocation)
+	if err != nil {
+		fmt.Printf("Unable to read config: %v\n", err)
+		os.Exit(1)
 	}
 
+	// A failed update keeps the versions fetched before, without any only installed versions can be used
+	// the failure is recorded so every run warns until an update succeeds, not only the one retrying it
+	failure, err := versionedTerraform.ConfigUpdateFailure(*fileHandle)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to read the last update of the available terraform versions: %v\n", err)
+	}
+	warning, onlyInstalled := updateWarning(failure, updateErr, len(versionsFromConfig), offline)
+	if warning != "" {
+		fmt.Fprintln(os.Stderr, warning)
+	}
+	offline = offline || onlyInstalled
+
 	// Load a slice of versions which have already been installed
 	installedVersions, err := versionedTerraform.LoadInstalledVersions(configDir)
 	if err != nil {
@@ -73,6 +130,10 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Offline only installed versions may be selected
+	if offline {
+		versionsFromConfig = installedVersions
+	}
 	var vSlice []string
 	for _, v := range versionsFromConfig {
 		vSlice = append(vSlice, v.ToString())
@@ -84,17 +145,73 @@ func main() {
 		fmt.Fprintf(os.Stderr, "Unable to open config file, defaulting to stable versions of terraform only")
 	}
 
+	// Select how versions satisfying the constraint are chosen
+	strategy, err := resolutionStrategy(*fileHandle, *strategyFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to determine resolution strategy: %v\n", err)
+		os.Exit(1)
+	}
+	opts := versionedTerraform.Options{
+		Strategy:          strategy,
+		InstalledVersions: installedVersions,
+		StableOnly:        needsStable,
+	}
+
+	// Check if version files such as .terraform-version take precedence over required_version
+	preferVersionFile, err := versionedTerraform.ConfigPrefersVersionFile(*fileHandle)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to open config file, defaulting to prefer version files")
+	}
+	opts.PreferRequiredVersion = !preferVersionFile
+
+	// Limit the parent directories searched for version sources
+	opts.SearchBoundary, err = searchBoundary(*fileHandle)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to determine search boundary: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Run wrapper commands instead of terraform
+	if len(args) > 0 && args[0] == wrapperCommand {
+		os.Exit(runWrapperCommand(args[1:], vSlice, opts))
+	}
+
 	// Load version required from terraform directory
-	ver, err := versionedTerraform.GetVersionFromFile(workingDir, vSlice, needsStable)
+	ver, err := getVersionFromDir(workingDir, vSlice, opts)
+	if err != nil && offline {
+		fmt.Fprintf(os.Stderr, "Unable to retrieve terraform version from files while offline, "+
+			"only installed versions can be used%s: %v\n", installedList(installedVersions), err)
+		os.Exit(1)
+	}
 	if err != nil {
-		fmt.Printf("Unable to retrieve terraform version from files: %v", err)
+		fmt.Fprintf(os.Stderr, "Unable to retrieve terraform version from files: %v\n", err)
+		os.Exit(1)
+	}
+	printWarnings(ver)
+	if sourceDir, err := filepath.Abs(workingDir); err == nil && absoluteDir(ver.Dir) != sourceDir {
+		fmt.Fprintf(os.Stderr, "Using terraform version %s required by %s\n", ver.VersionToString(), absoluteDir(ver.Dir))
 	}
 
 	if !ver.Version.VersionInSlice(installedVersions) {
 		fmt.Printf("Installing terraform version %s\n\n", ver.Version.ToString())
 		err = ver.InstallTerraformVersion()
-		if err != nil {
-			fmt.Printf("Unable to install terraform version: %v", err)
+		if versionedTerraform.IsNetworkError(err) {
+			// the network is unreachable, fall back to the installed versions
+			fmt.Fprintf(os.Stderr, "Unable to download terraform version %s, only installed versions are used: %v\n",
+				ver.VersionToString(), err)
+			var installedSlice []string
+			for _, v := range installedVersions {
+				installedSlice = append(installedSlice, v.ToString())
+			}
+			ver, err = getVersionFromDir(workingDir, installedSlice, opts)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Unable to retrieve terraform version from installed versions%s: %v\n",
+					installedList(installedVersions), err)
+				os.Exit(1)
+			}
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to install terraform version: %v\n", err)
+			os.Exit(1)
 		}
 	}
 
@@ -116,3 +233,177 @@ func main() {
 		}
 	}
 }
+
+// runWrapperCommand runs a versionedTerraform command and returns the exit code
+// these are reached with "versionedTerraform vt <command>" and never run terraform
+func runWrapperCommand(args []string, versionList []string, opts versionedTerraform.Options) int {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "Usage: versionedTerraform %s <command>\n\nCommands:\n%s", wrapperCommand, wrapperCommandUsage)
+		return 1
+	}
+
+	switch args[0] {
+	case resolveCommand:
+		return runResolve(args[1:], versionList, opts)
+	case matrixCommand:
+		return runMatrix(args[1:], versionList, opts)
+	}
+
+	fmt.Fprintf(os.Stderr, "Unknown command %q\n\nCommands:\n%s", args[0], wrapperCommandUsage)
+	return 1
+}
+
+// resolutionStrategy returns the Strategy from the --vt-strategy flag, the environment
+// or the configuration file in that order of precedence
+func resolutionStrategy(configFile os.File, flagValue string) (versionedTerraform.Strategy, error) {
+	if flagValue != "" {
+		return versionedTerraform.ParseStrategy(flagValue)
+	}
+	if envValue := os.Getenv(strategyEnv); envValue != "" {
+		return versionedTerraform.ParseStrategy(envValue)
+	}
+	return versionedTerraform.ConfigStrategy(configFile)
+}
+
+// searchBoundary returns the directory above which version sources are not searched, relative to the
+// filesystem root, from the environment or the configuration file in that order of precedence
+func searchBoundary(configFile os.File) (string, error) {
+	boundary := os.Getenv(searchBoundaryEnv)
+	if boundary == "" {
+		var err error
+		boundary, err = versionedTerraform.ConfigSearchBoundary(configFile)
+		if err != nil || boundary == "" {
+			return "", err
+		}
+	}
+	_, relBoundary, err := rootRelative(boundary)
+	return relBoundary, err
+}
+
+// offlineMode returns true if the network must not be used from the --vt-offline flag, the environment
+// or the configuration file in that order of precedence
+func offlineMode(configFileName string) (bool, error) {
+	if *offlineFlag {
+		return true, nil
+	}
+	if envValue := os.Getenv(offlineEnv); envValue != "" {
+		return strconv.ParseBool(envValue)
+	}
+	configFile, err := os.Open(configFileName)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer configFile.Close()
+	return versionedTerraform.ConfigOffline(*configFile)
+}
+
+// installedList returns the installed versions as " (installed: 1.5.7, 1.4.6)" or " (none installed)"
+func installedList(installed []versionedTerraform.SemVersion) string {
+	if len(installed) == 0 {
+		return " (none installed)"
+	}
+	return " (installed" + versionList(installed) + ")"
+}
+
+// setReleaseUrls sets the mirror and URL templates versions are fetched from with the environment or
+// the configuration file in that order of precedence, releases.hashicorp.com is used if neither is set
+func setReleaseUrls(configFileName string) error {
+	var mirrorUrl, index, download, checksum string
+	configFile, err := os.Open(configFileName)
+	if err == nil {
+		defer configFile.Close()
+		if mirrorUrl, err = versionedTerraform.ConfigMirrorUrl(*configFile); err != nil {
+			return err
+		}
+		if index, download, checksum, err = versionedTerraform.ConfigUrlTemplates(*configFile); err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	for env, value := range map[string]*string{
+		mirrorUrlEnv:        &mirrorUrl,
+		indexUrlEnv:         &index,
+		downloadTemplateEnv: &download,
+		checksumTemplateEnv: &checksum,
+	} {
+		if envValue := os.Getenv(env); envValue != "" {
+			*value = envValue
+		}
+	}
+
+	if mirrorUrl != "" {
+		if err := versionedTerraform.SetMirrorUrl(mirrorUrl); err != nil {
+			return err
+		}
+	}
+	return versionedTerraform.SetUrlTemplates(index, download, checksum)
+}
+
+// getVersionFromDir resolves the version required by the terraform files in dir
+// the search starts from the filesystem root so that modules such as ../shared and parent directories can be read
+func getVersionFromDir(dir string, versionList []string, opts versionedTerraform.Options) (*versionedTerraform.Version, error) {
+	root, relDir, err := rootRelative(dir)
+	if err != nil {
+		return &versionedTerraform.Version{}, err
+	}
+	return opts.GetVersionFromDir(os.DirFS(root), relDir, versionList)
+}
+
+// rootRelative returns the filesystem root of dir and the slash separated path of dir within it
+func rootRelative(dir string) (string, string, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", "", err
+	}
+	root := filepath.VolumeName(absDir) + string(filepath.Separator)
+	relDir, err := filepath.Rel(root, absDir)
+	if err != nil {
+		return "", "", err
+	}
+	return root, filepath.ToSlash(relDir), nil
+}
+
+// updateWarning returns the warning about a failed update of the available versions and whether only installed
+// versions can be used as the release index has not been reached since the configuration was created
+// there is no warning in offline mode as the network is not used
+func updateWarning(failure *versionedTerraform.UpdateFailure, updateErr error, cachedVersions int, offline bool) (string, bool) {
+	switch {
+	case offline:
+		return "", false
+	case failure != nil && cachedVersions > 0:
+		return fmt.Sprintf("Unable to update the available terraform versions, the versions fetched before are used "+
+			"(%s): %s", failedAttempts(failure), failure.Error), false
+	case failure != nil && failure.NetworkError:
+		return fmt.Sprintf("Unable to reach the release index, only installed versions are used (%s): %s",
+			failedAttempts(failure), failure.Error), true
+	case failure != nil:
+		return fmt.Sprintf("Unable to update the available terraform versions (%s): %s",
+			failedAttempts(failure), failure.Error), false
+	case updateErr != nil:
+		return fmt.Sprintf("Unable to update the available terraform versions: %v", updateErr), false
+	}
+	return "", false
+}
+
+// failedAttempts describes the number of failed updates and the time of the last one
+func failedAttempts(failure *versionedTerraform.UpdateFailure) string {
+	return fmt.Sprintf("failed updates: %d, the last at %s", failure.Count, failure.Time.Format(time.RFC1123))
+}
+
+// printWarnings prints the modules skipped while resolving ver
+func printWarnings(ver *versionedTerraform.Version) {
+	for _, warning := range ver.Warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+}
+
+// absoluteDir returns the absolute path of a directory returned as Version.Dir by getVersionFromDir
+func absoluteDir(dir string) string {
+	absPwd, _ := filepath.Abs(pwd)
+	return filepath.Join(filepath.VolumeName(absPwd)+string(filepath.Separator), filepath.FromSlash(dir))
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"versionedTerraform"
+)
+
+func TestUpdateWarning_firstRunOffline(t *testing.T) {
+	// an air-gapped machine with terraform installed but without a configuration
+	defer versionedTerraform.SetMirrorUrl("https://releases.hashicorp.com/terraform/")
+	if err := versionedTerraform.SetMirrorUrl("http://127.0.0.1:1/terraform/"); err != nil {
+		t.Fatal(err)
+	}
+	configDir := t.TempDir()
+	os.WriteFile(filepath.Join(configDir, "terraform_1.5.7"), []byte("#!/bin/sh\n"), 0755)
+
+	if err := versionedTerraform.CreateConfig(configDir, configFileLocation); err != nil {
+		t.Fatal(err)
+	}
+	needsUpdate, err := versionedTerraform.NeedToUpdateAvailableVersions(os.DirFS(configDir), configFileLocation)
+	if err != nil || needsUpdate {
+		t.Errorf("Expected the update to wait after the failure while creating the configuration, got %t, %v", needsUpdate, err)
+	}
+	cached, err := versionedTerraform.LoadVersionsFromConfig(os.DirFS(configDir), configFileLocation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileHandle, err := os.Open(filepath.Join(configDir, configFileLocation))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fileHandle.Close()
+	failure, err := versionedTerraform.ConfigUpdateFailure(*fileHandle)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	warning, onlyInstalled := updateWarning(failure, nil, len(cached), false)
+	if !onlyInstalled || !strings.HasPrefix(warning, "Unable to reach the release index, only installed versions are used") {
+		t.Fatalf("Expected only installed versions to be used with a warning, got %t, %q", onlyInstalled, warning)
+	}
+	installed, err := versionedTerraform.LoadInstalledVersions(os.DirFS(configDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var versionList []string
+	for _, version := range installed {
+		versionList = append(versionList, version.ToString())
+	}
+	version, err := versionedTerraform.GetVersionFromConstraint(">= 1.0", versionList, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version.VersionToString() != "1.5.7" {
+		t.Errorf("Expected the installed 1.5.7, got %s", version.VersionToString())
+	}
+}
+
+func TestUpdateWarning(t *testing.T) {
+	failure := &versionedTerraform.UpdateFailure{Time: time.Unix(1286705470, 0), Count: 2, Error: "unable to fetch"}
+	networkFailure := &versionedTerraform.UpdateFailure{Time: time.Unix(1286705470, 0), Count: 1, Error: "refused",
+		NetworkError: true}
+
+	cases := []struct {
+		name              string
+		failure           *versionedTerraform.UpdateFailure
+		updateErr         error
+		cachedVersions    int
+		offline           bool
+		wantPrefix        string
+		wantOnlyInstalled bool
+	}{
+		{"last update succeeded", nil, nil, 3, false, "", false},
+		{"versions fetched before", networkFailure, nil, 3, false,
+			"Unable to update the available terraform versions, the versions fetched before are used", false},
+		{"never reached", networkFailure, nil, 0, false,
+			"Unable to reach the release index, only installed versions are used", true},
+		{"invalid release index", failure, nil, 0, false, "Unable to update the available terraform versions (failed updates: 2", false},
+		{"unrecorded error", nil, errors.New("unable to write"), 3, false,
+			"Unable to update the available terraform versions: unable to write", false},
+		{"offline", networkFailure, nil, 0, true, "", false},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run("Test: "+c.name, func(t *testing.T) {
+			warning, onlyInstalled := updateWarning(c.failure, c.updateErr, c.cachedVersions, c.offline)
+			if !strings.HasPrefix(warning, c.wantPrefix) || (c.wantPrefix == "") != (warning == "") {
+				t.Errorf("Expected a warning starting with %q, got %q", c.wantPrefix, warning)
+			}
+			if onlyInstalled != c.wantOnlyInstalled {
+				t.Errorf("Expected only installed versions %t, got %t", c.wantOnlyInstalled, onlyInstalled)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"versionedTerraform"
+)
+
+const matrixCommand = "matrix"
+
+// runMatrix prints the versions to test a directory or constraint against as a JSON array
+// suitable for a CI matrix, one entry for the newest patch of every satisfying minor release
+func runMatrix(args []string, versionList []string, opts versionedTerraform.Options) int {
+	flags := flag.NewFlagSet(matrixCommand, flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	ver, err := versionForTarget(commandTarget(flags.Args()), versionList, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to resolve terraform versions: %v\n", err)
+		return 1
+	}
+
+	matrix := []string{}
+	for _, v := range ver.Matrix() {
+		matrix = append(matrix, v.ToString())
+	}
+
+	output, err := json.Marshal(matrix)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to write matrix: %v\n", err)
+		return 1
+	}
+	fmt.Println(string(output))
+	return 0
+}
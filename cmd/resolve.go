@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"versionedTerraform"
+)
+
+const resolveCommand = "resolve"
+
+// runResolve prints the version resolved from a directory or a literal constraint
+// without downloading or running terraform
+func runResolve(args []string, versionList []string, opts versionedTerraform.Options) int {
+	flags := flag.NewFlagSet(resolveCommand, flag.ContinueOnError)
+	explain := flags.Bool("explain", false, "show which versions each clause eliminated")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	target := commandTarget(flags.Args())
+	ver, err := versionForTarget(target, versionList, opts)
+	printWarnings(ver)
+
+	var unsatisfiable *versionedTerraform.UnsatisfiableError
+	if *explain && (err == nil || errors.As(err, &unsatisfiable)) {
+		printExplanation(os.Stdout, ver.Explain())
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to resolve terraform version: %v\n", err)
+		return 1
+	}
+
+	if !*explain {
+		fmt.Println(ver.VersionToString())
+	}
+	return 0
+}
+
+// commandTarget returns the directory or constraint given to a wrapper command
+// defaults to the current directory
+func commandTarget(args []string) string {
+	if len(args) == 0 {
+		return pwd
+	}
+	return strings.Join(args, " ")
+}
+
+// versionForTarget resolves the version required by a directory, or by target itself
+// if it is not a directory, exactly as it would be resolved before executing terraform
+func versionForTarget(target string, versionList []string, opts versionedTerraform.Options) (*versionedTerraform.Version, error) {
+	if info, err := os.Stat(target); err == nil && info.IsDir() {
+		return getVersionFromDir(target, versionList, opts)
+	}
+	return opts.NewVersion(target, versionList)
+}
+
+// printExplanation writes each step of the version resolution
+func printExplanation(w io.Writer, explanation versionedTerraform.Explanation) {
+	if explanation.Dir != "" {
+		fmt.Fprintf(w, "directory: %s\n", absoluteDir(explanation.Dir))
+	}
+	for _, source := range explanation.Sources {
+		kind := "required_version"
+		if source.IsVersionFile() {
+			kind = "version"
+		}
+		fmt.Fprintf(w, "%s %q from %s line %d\n", kind, source.Constraint, source.File, source.Line)
+	}
+	fmt.Fprintf(w, "constraint: %s\n", explanation.Constraints.String())
+	fmt.Fprintf(w, "available versions: %d\n", len(explanation.Available))
+	if explanation.StableOnly {
+		fmt.Fprintf(w, "StableOnly: true, eliminated %d pre-release version(s)%s\n",
+			len(explanation.StableFiltered), versionList(explanation.StableFiltered))
+	} else {
+		fmt.Fprintf(w, "StableOnly: false, pre-release versions are candidates\n")
+	}
+	fmt.Fprintf(w, "strategy: %s\n", explanation.Strategy)
+	for _, clause := range explanation.Clauses {
+		fmt.Fprintf(w, "clause %q%s eliminated %d version(s)%s\n",
+			clause.Constraint.String(), sourceList(clause.Sources), len(clause.Eliminated), versionList(clause.Eliminated))
+	}
+	if explanation.Selected.ToString() != "" {
+		fmt.Fprintf(w, "selected: %s\n", explanation.Selected.ToString())
+	}
+}
+
+// sourceList returns the files and lines of sources as " from <file> line <n>, ..."
+func sourceList(sources []versionedTerraform.VersionSource) string {
+	if len(sources) == 0 {
+		return ""
+	}
+	var sourceStrings []string
+	for _, source := range sources {
+		sourceStrings = append(sourceStrings, fmt.Sprintf("%s line %d", source.File, source.Line))
+	}
+	return " from " + strings.Join(sourceStrings, ", ")
+}
+
+// versionList returns versions as a comma separated list prefixed with ": "
+func versionList(versions []versionedTerraform.SemVersion) string {
+	if len(versions) == 0 {
+		return ""
+	}
+	var versionStrings []string
+	for _, v := range versions {
+		versionStrings = append(versionStrings, v.ToString())
+	}
+	return ": " + strings.Join(versionStrings, ", ")
+}
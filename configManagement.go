@@ -2,19 +2,56 @@ package versionedTerraform
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io/fs"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
 	"time"
 )
 
+const (
+	stableOnlyKey        = "StableOnly"
+	lastUpdateKey        = "LastUpdate"
+	availableVersionsKey = "AvailableVersions"
+	strategyKey          = "Strategy"
+	preferVersionFileKey = "PreferVersionFile"
+	searchBoundaryKey    = "SearchBoundary"
+	mirrorUrlKey         = "MirrorUrl"
+	indexUrlKey          = "IndexUrl"
+	downloadTemplateKey  = "DownloadUrlTemplate"
+	checksumTemplateKey  = "ChecksumUrlTemplate"
+	offlineKey           = "Offline"
+	lastFailureKey       = "LastFailure"
+	failedUpdatesKey     = "FailedUpdates"
+	lastErrorKey         = "LastError"
+	networkErrorKey      = "NetworkError"
+)
+
+const (
+	// retryDelay is the time waited after a failed update of the available versions before trying again
+	// it doubles with each consecutive failure up to maxRetryDelay
+	retryDelay    = time.Minute
+	maxRetryDelay = time.Hour
+)
+
 type configStruct struct {
 	StableOnly        bool
 	LastUpdate        int64
 	AvailableVersions []string
+	preservedLines    []string
+}
+
+// UpdateFailure describes the consecutive failed updates of the available versions recorded in the configuration file
+// NetworkError is true if the last update failed as the release index could not be reached
+type UpdateFailure struct {
+	Time         time.Time
+	Count        int
+	Error        string
+	NetworkError bool
 }
 
 //ConfigRequiresStable returns bool, error only false if StableOnly: false is set in configuration file
@@ -40,12 +77,144 @@ func ConfigRequiresStable(File os.File) (bool, error) {
 	return true, nil
 }
 
-//NeedToUpdateAvailableVersions returns bool, error checks if last update was older than 1 day ago
+//ConfigOffline returns bool, error only true if Offline: true is set in configuration file
+func ConfigOffline(File os.File) (bool, error) {
+	value, _, err := configValue(File.Name(), offlineKey)
+	return strings.EqualFold(value, "true"), err
+}
+
+//ConfigStrategy returns the Strategy and an error from the Strategy: value in the configuration file
+//defaults to StrategyNewest if the value is not set
+func ConfigStrategy(File os.File) (Strategy, error) {
+	value, isSet, err := configValue(File.Name(), strategyKey)
+	if err != nil || !isSet {
+		return StrategyNewest, err
+	}
+	return ParseStrategy(value)
+}
+
+//ConfigPrefersVersionFile returns bool, error only false if PreferVersionFile: false is set in configuration file
+func ConfigPrefersVersionFile(File os.File) (bool, error) {
+	value, isSet, err := configValue(File.Name(), preferVersionFileKey)
+	if err != nil || !isSet {
+		return true, err
+	}
+	return !strings.EqualFold(value, "false"), nil
+}
+
+//ConfigSearchBoundary returns the SearchBoundary: directory from the configuration file and an error
+//returns an empty string if the value is not set
+func ConfigSearchBoundary(File os.File) (string, error) {
+	value, _, err := configValue(File.Name(), searchBoundaryKey)
+	return value, err
+}
+
+//ConfigMirrorUrl returns the MirrorUrl: value from the configuration file and an error
+//returns an empty string if the value is not set
+func ConfigMirrorUrl(File os.File) (string, error) {
+	value, _, err := configValue(File.Name(), mirrorUrlKey)
+	return value, err
+}
+
+//ConfigUrlTemplates returns the IndexUrl:, DownloadUrlTemplate: and ChecksumUrlTemplate: values from the
+//configuration file and an error, values which are not set are returned as empty strings
+func ConfigUrlTemplates(File os.File) (string, string, string, error) {
+	var values []string
+	for _, key := range []string{indexUrlKey, downloadTemplateKey, checksumTemplateKey} {
+		value, _, err := configValue(File.Name(), key)
+		if err != nil {
+			return "", "", "", err
+		}
+		values = append(values, value)
+	}
+	return values[0], values[1], values[2], nil
+}
+
+//ConfigUpdateFailure returns the failed updates recorded in the configuration file since the last successful update
+//and an error, returns nil if the last update succeeded
+func ConfigUpdateFailure(File os.File) (*UpdateFailure, error) {
+	value, isSet, err := configValue(File.Name(), lastFailureKey)
+	if err != nil || !isSet {
+		return nil, err
+	}
+	lastFailure, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	failure := &UpdateFailure{Time: time.Unix(lastFailure, 0)}
+
+	value, _, err = configValue(File.Name(), failedUpdatesKey)
+	if err != nil {
+		return failure, err
+	}
+	failure.Count, _ = strconv.Atoi(value)
+	failure.Error, _, err = configValue(File.Name(), lastErrorKey)
+	if err != nil {
+		return failure, err
+	}
+	value, _, err = configValue(File.Name(), networkErrorKey)
+	failure.NetworkError = strings.EqualFold(value, "true")
+	return failure, err
+}
+
+//configValue returns the value of key in the configuration file and whether it was set
+func configValue(fileName string, key string) (string, bool, error) {
+	fileHandle, err := os.Open(fileName)
+	if err != nil {
+		return "", false, err
+	}
+	defer fileHandle.Close()
+
+	fileScanner := bufio.NewScanner(fileHandle)
+	fileScanner.Split(bufio.ScanLines)
+
+	for fileScanner.Scan() {
+		_line := fileScanner.Text()
+		if strings.HasPrefix(_line, key+": ") {
+			return strings.TrimSpace(strings.SplitAfterN(_line, key+": ", 2)[1]), true, nil
+		}
+	}
+	return "", false, nil
+}
+
+//preservedConfigLines returns the lines of the configuration file which do not set one of managedKeys
+func preservedConfigLines(fileName string, managedKeys ...string) []string {
+	var lines []string
+	fileHandle, err := os.Open(fileName)
+	if err != nil {
+		return lines
+	}
+	defer fileHandle.Close()
+
+	fileScanner := bufio.NewScanner(fileHandle)
+	fileScanner.Split(bufio.ScanLines)
+
+	for fileScanner.Scan() {
+		_line := fileScanner.Text()
+		if strings.TrimSpace(_line) == "" {
+			continue
+		}
+		isManaged := false
+		for _, key := range managedKeys {
+			if strings.HasPrefix(_line, key+":") {
+				isManaged = true
+			}
+		}
+		if !isManaged {
+			lines = append(lines, _line)
+		}
+	}
+	return lines
+}
+
+//NeedToUpdateAvailableVersions returns bool, error checks if last update was older than 1 day ago or never happened
 // this prevents us from spamming the list of available terraform versions page
+// after a failed update it is only retried once the delay of updateRetryDelay has passed
 func NeedToUpdateAvailableVersions(fileSystem fs.FS, availableVersions string) (bool, error) {
 	//todo this is used a lot abstract it?
 	fileHandle, err := fileSystem.Open(availableVersions)
-	oneDayAgo := time.Now().AddDate(0, 0, -1).Unix()
+	now := time.Now()
+	oneDayAgo := now.AddDate(0, 0, -1).Unix()
 	if err != nil {
 		return false, err
 	}
@@ -54,21 +223,49 @@ func NeedToUpdateAvailableVersions(fileSystem fs.FS, availableVersions string) (
 	fileScanner := bufio.NewScanner(fileHandle)
 	fileScanner.Split(bufio.ScanLines)
 
+	// the available versions have never been fetched when LastUpdate is missing, i.e. the configuration was created offline
+	needsUpdate := true
+	var lastFailure int64
+	failedUpdates := 0
 	for fileScanner.Scan() {
 		_line := fileScanner.Text()
-		if strings.Contains(_line, "LastUpdate: ") {
+		switch {
+		case strings.HasPrefix(_line, lastUpdateKey+": "):
 			lastUpdateTimeString := strings.SplitAfter(_line, "LastUpdate: ")[1]
 			lastUpdateTimeString = strings.TrimSpace(lastUpdateTimeString)
 			lastUpdateTime, err := strconv.ParseInt(lastUpdateTimeString, 10, 64)
 			if err != nil {
 				return false, err
 			}
-			if lastUpdateTime <= oneDayAgo {
-				return true, nil
+			needsUpdate = lastUpdateTime <= oneDayAgo
+		case strings.HasPrefix(_line, lastFailureKey+": "):
+			lastFailure, err = strconv.ParseInt(strings.TrimSpace(strings.SplitAfter(_line, lastFailureKey+": ")[1]), 10, 64)
+			if err != nil {
+				return false, err
+			}
+		case strings.HasPrefix(_line, failedUpdatesKey+": "):
+			failedUpdates, err = strconv.Atoi(strings.TrimSpace(strings.SplitAfter(_line, failedUpdatesKey+": ")[1]))
+			if err != nil {
+				return false, err
 			}
 		}
 	}
-	return false, nil
+	if needsUpdate && lastFailure != 0 {
+		return !now.Before(time.Unix(lastFailure, 0).Add(updateRetryDelay(failedUpdates))), nil
+	}
+	return needsUpdate, nil
+}
+
+//updateRetryDelay returns the time to wait before updating the available versions after failedUpdates consecutive failures
+func updateRetryDelay(failedUpdates int) time.Duration {
+	delay := retryDelay
+	for i := 1; i < failedUpdates && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		return maxRetryDelay
+	}
+	return delay
 }
 
 //LoadVersionsFromConfig returns slice of SemVersions and an error from AvailableVersions in configuration file
@@ -92,9 +289,13 @@ func LoadVersionsFromConfig(fileSystem fs.FS, configFile string) ([]SemVersion,
 			_line = strings.SplitAfter(_line, "AvailableVersions: ")[1]
 			_line = removeOpenBracket.ReplaceAllString(_line, "")
 			_line = removeCloseBracket.ReplaceAllString(_line, "")
-			versions := strings.Split(_line, " ")
+			versions := strings.Fields(_line)
 			for _, version := range versions {
-				versionList = append(versionList, *NewSemVersion(version))
+				semVersion, err := ParseSemVersion(version)
+				if err != nil {
+					return nil, fmt.Errorf("invalid available version in %s: %w", configFile, err)
+				}
+				versionList = append(versionList, semVersion)
 			}
 			return versionList, nil
 		}
@@ -115,29 +316,50 @@ func LoadInstalledVersions(fileSystem fs.FS) ([]SemVersion, error) {
 		terraformFileName := f.Name()
 		if strings.Contains(terraformFileName, terraformPrefix) {
 			terraformVersionString := terraformRegex.ReplaceAllString(terraformFileName, "")
-			installedTerraformVersions = append(installedTerraformVersions, *NewSemVersion(terraformVersionString))
+			semVersion, err := ParseSemVersion(terraformVersionString)
+			if err != nil {
+				// not a terraform binary installed by versionedTerraform
+				continue
+			}
+			installedTerraformVersions = append(installedTerraformVersions, semVersion)
 		}
 	}
 	return installedTerraformVersions, nil
 }
 
-//UpdateConfig returns an error, and updates configuration file
+//UpdateConfig returns an error if the available versions could not be fetched, and updates configuration file
 // adding:
 // a new date to the last updated field
-// the available versions listed on terraforms website
+// the available versions listed in terraforms release index, the full index is stored in releases.json
 // the status of if the user wants only stable releases
+// any other settings such as Strategy are kept unchanged
+// if the release index cannot be fetched the versions fetched before are kept and only the failure is recorded
+// if releases.json cannot be written the configuration file is still updated and the error is returned
 func UpdateConfig(File os.File, timeNow ...time.Time) error {
 	configValues := new(configStruct)
 
-	configValues.AvailableVersions, _ = GetVersionList()
-	configValues.StableOnly, _ = ConfigRequiresStable(File)
-
 	var t time.Time
 	if len(timeNow) > 0 {
 		t = timeNow[0]
 	} else {
 		t = time.Now()
 	}
+
+	// the release index is stored next to the configuration file for installs
+	index, err := GetReleaseIndex()
+	if errors.Is(err, ErrOffline) {
+		// the versions fetched before are kept until the network may be used
+		return err
+	}
+	if err != nil {
+		recordFailedUpdate(File, t, err)
+		return err
+	}
+	configValues.AvailableVersions = index.VersionList()
+	saveErr := index.SaveReleaseIndex(filepath.Join(filepath.Dir(File.Name()), ReleaseCacheFile))
+	configValues.StableOnly, _ = ConfigRequiresStable(File)
+	configValues.preservedLines = preservedConfigLines(File.Name(),
+		stableOnlyKey, lastUpdateKey, availableVersionsKey, lastFailureKey, failedUpdatesKey, lastErrorKey, networkErrorKey)
 	configValues.LastUpdate = t.Unix()
 
 	File.Truncate(0)
@@ -150,9 +372,39 @@ func UpdateConfig(File os.File, timeNow ...time.Time) error {
 	File.Write(lineToByte)
 	lineToByte = []byte(fmt.Sprintf("AvailableVersions: %+v\n", configValues.AvailableVersions))
 	File.Write(lineToByte)
+
+	// settings other than the above are kept as they were
+	for _, line := range configValues.preservedLines {
+		File.Write([]byte(line + "\n"))
+	}
+	if saveErr != nil {
+		// installs fetch the release index again until it can be stored
+		return fmt.Errorf("unable to store the release index in %s: %w", ReleaseCacheFile, saveErr)
+	}
 	return nil
 }
 
+//recordFailedUpdate updates the configuration file with the time and error of a failed update and the number of
+// consecutive failures, the available versions and the time of the last successful update are kept
+func recordFailedUpdate(File os.File, t time.Time, updateErr error) {
+	failedUpdates := 0
+	if value, _, err := configValue(File.Name(), failedUpdatesKey); err == nil {
+		failedUpdates, _ = strconv.Atoi(value)
+	}
+	lines := preservedConfigLines(File.Name(), lastFailureKey, failedUpdatesKey, lastErrorKey, networkErrorKey)
+
+	File.Truncate(0)
+	File.Seek(0, 0)
+
+	for _, line := range lines {
+		File.Write([]byte(line + "\n"))
+	}
+	File.Write([]byte(fmt.Sprintf("%s: %d\n", lastFailureKey, t.Unix())))
+	File.Write([]byte(fmt.Sprintf("%s: %d\n", failedUpdatesKey, failedUpdates+1)))
+	File.Write([]byte(fmt.Sprintf("%s: %s\n", lastErrorKey, strings.ReplaceAll(updateErr.Error(), "\n", " "))))
+	File.Write([]byte(fmt.Sprintf("%s: %t\n", networkErrorKey, IsNetworkError(updateErr))))
+}
+
 //CreateConfig returns error, creates a new configuration file
 func CreateConfig(directory string, configFile string) error {
 	configFileName := directory + "/" + configFile
@@ -166,6 +418,15 @@ func CreateConfig(directory string, configFile string) error {
 
 	lineToByte := []byte(fmt.Sprintf("StableOnly: true\n"))
 	fileHandler.Write(lineToByte)
+	lineToByte = []byte(fmt.Sprintf("Strategy: %s\n", StrategyNewest))
+	fileHandler.Write(lineToByte)
+	lineToByte = []byte(fmt.Sprintf("PreferVersionFile: true\n"))
+	fileHandler.Write(lineToByte)
 	err = UpdateConfig(*fileHandler)
+	if IsNetworkError(err) {
+		// the configuration is created without available versions while the network is unreachable
+		// the failure is recorded for ConfigUpdateFailure
+		return nil
+	}
 	return err
 }
@@ -1,9 +1,11 @@
 package versionedTerraform
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"reflect"
 	"sort"
 	"strings"
@@ -20,9 +22,15 @@ func TestUpdateAvailableVersions(t *testing.T) {
 	successUpdate := fmt.Sprintf("LastUpdate: %d", currentTime)
 	needsUpdate := fmt.Sprintf("LastUpdate: %d", twoDaysAgoTime)
 
+	retryLater := fmt.Sprintf("%s\nLastFailure: %d\nFailedUpdates: 3\n", needsUpdate, timeNow.Add(-3*time.Minute).Unix())
+	retryNow := fmt.Sprintf("%s\nLastFailure: %d\nFailedUpdates: 3\n", needsUpdate, timeNow.Add(-5*time.Minute).Unix())
+
 	fs := fstest.MapFS{
-		"successConfig.conf": {Data: []byte(successUpdate)},
-		"failConfig.conf":    {Data: []byte(needsUpdate)},
+		"successConfig.conf":    {Data: []byte(successUpdate)},
+		"failConfig.conf":       {Data: []byte(needsUpdate)},
+		"offlineConfig.conf":    {Data: []byte("StableOnly: true\nStrategy: newest\n")},
+		"retryLaterConfig.conf": {Data: []byte(retryLater)},
+		"retryNowConfig.conf":   {Data: []byte(retryNow)},
 	}
 
 	t.Run("Test success last update time", func(t *testing.T) {
@@ -48,6 +56,51 @@ func TestUpdateAvailableVersions(t *testing.T) {
 			t.Errorf("updateAvailableVersions had incorrect output expected %v got %v", want, got)
 		}
 	})
+
+	t.Run("Test missing last update time", func(t *testing.T) {
+		want := true
+		got, err := NeedToUpdateAvailableVersions(fs, "offlineConfig.conf")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got != want {
+			t.Errorf("updateAvailableVersions had incorrect output expected %v got %v", want, got)
+		}
+	})
+
+	t.Run("Test retry delay after failed updates", func(t *testing.T) {
+		for name, want := range map[string]bool{"retryLaterConfig.conf": false, "retryNowConfig.conf": true} {
+			got, err := NeedToUpdateAvailableVersions(fs, name)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != want {
+				t.Errorf("updateAvailableVersions had incorrect output for %s expected %v got %v", name, want, got)
+			}
+		}
+	})
+}
+
+func TestUpdateRetryDelay(t *testing.T) {
+	cases := []struct {
+		failedUpdates int
+		want          time.Duration
+	}{
+		{0, time.Minute},
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{3, 4 * time.Minute},
+		{7, time.Hour},
+		{100, time.Hour},
+	}
+
+	for _, c := range cases {
+		if got := updateRetryDelay(c.failedUpdates); got != c.want {
+			t.Errorf("updateRetryDelay(%d) expected %v got %v", c.failedUpdates, c.want, got)
+		}
+	}
 }
 
 func TestAvailableVersions(t *testing.T) {
@@ -73,6 +126,97 @@ func TestAvailableVersions(t *testing.T) {
 	})
 }
 
+func TestAvailableVersions_empty(t *testing.T) {
+	fs := fstest.MapFS{
+		"emptyConfig.conf":   {Data: []byte("AvailableVersions: []")},
+		"invalidConfig.conf": {Data: []byte("AvailableVersions: [1.2.3 1.x]")},
+	}
+
+	got, err := LoadVersionsFromConfig(fs, "emptyConfig.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("LoadVersionsFromConfig expected no versions got %+v", got)
+	}
+
+	_, err = LoadVersionsFromConfig(fs, "invalidConfig.conf")
+	if err == nil {
+		t.Errorf("LoadVersionsFromConfig expected an error for an invalid version")
+	}
+}
+
+func TestConfigStrategy(t *testing.T) {
+	cases := []struct {
+		name, content string
+		want          Strategy
+		isValid       bool
+	}{
+		{"Strategy not found", "StableOnly: true\n", StrategyNewest, true},
+		{"Strategy oldest", "StableOnly: true\nStrategy: oldest\n", StrategyOldest, true},
+		{"Strategy prefer-installed", "Strategy: prefer-installed\n", StrategyPreferInstalled, true},
+		{"Strategy invalid", "Strategy: fastest\n", StrategyNewest, false},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run("Test: "+c.name, func(t *testing.T) {
+			t.Parallel()
+			tempFile, err := os.CreateTemp(t.TempDir(), "config")
+			if err != nil {
+				t.Fatalf("Unable to execute test : %v", err)
+			}
+			defer tempFile.Close()
+			tempFile.WriteString(c.content)
+
+			got, err := ConfigStrategy(*tempFile)
+			if (err == nil) != c.isValid {
+				t.Errorf("ConfigStrategy returned error %v, expected valid %t", err, c.isValid)
+			}
+			if got != c.want {
+				t.Errorf("ConfigStrategy expected %q got %q", c.want, got)
+			}
+		})
+	}
+}
+
+func TestUpdateConfig_preservesSettings(t *testing.T) {
+	defer SetMirrorUrl(hashicorpUrl)
+	if err := SetMirrorUrl("file://" + filepath.ToSlash(testMirror(t, "1.5.7", ""))); err != nil {
+		t.Fatal(err)
+	}
+
+	tempFile, err := os.CreateTemp(t.TempDir(), "config")
+	if err != nil {
+		t.Fatalf("Unable to execute test : %v", err)
+	}
+	defer tempFile.Close()
+	tempFile.WriteString("StableOnly: false\nStrategy: oldest\nLastUpdate: 1674481203\nAvailableVersions: [1.3.7]\n")
+
+	if err := UpdateConfig(*tempFile, time.Date(2010, 10, 10, 10, 10, 10, 10, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	versions, err := LoadVersionsFromConfig(os.DirFS(filepath.Dir(tempFile.Name())), filepath.Base(tempFile.Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 1 || versions[0].ToString() != "1.5.7" {
+		t.Errorf("UpdateConfig expected the available versions [1.5.7] got %v", versions)
+	}
+
+	got, err := ConfigStrategy(*tempFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != StrategyOldest {
+		t.Errorf("UpdateConfig expected Strategy %q to be kept got %q", StrategyOldest, got)
+	}
+	isStable, _ := ConfigRequiresStable(*tempFile)
+	if isStable {
+		t.Errorf("UpdateConfig expected StableOnly false to be kept")
+	}
+}
+
 func TestInstalledVersions(t *testing.T) {
 	var want []SemVersion
 	testVersionList := testVersionList()
@@ -119,8 +263,11 @@ func TestInstalledVersions(t *testing.T) {
 }
 
 func TestConfigRequiresStable(t *testing.T) {
-	availableVersions, _ := GetVersionList()
-	versions := strings.Join(availableVersions, " ")
+	t.Cleanup(func() { SetMirrorUrl(hashicorpUrl) })
+	if err := SetMirrorUrl("file://" + filepath.ToSlash(testMirror(t, "1.5.7", ""))); err != nil {
+		t.Fatal(err)
+	}
+	versions := "1.5.7"
 	cases := []struct {
 		name, content, want string
 		timeNow             time.Time
@@ -151,18 +298,20 @@ func TestConfigRequiresStable(t *testing.T) {
 	}
 
 	for _, c := range cases {
+		c := c
 		t.Run("Test: "+c.name, func(t *testing.T) {
 			t.Parallel()
 
-			tempDir := os.TempDir()
-			tempFile, err := os.Create(tempDir + "/config")
-			defer tempFile.Close()
-
+			tempFile, err := os.Create(filepath.Join(t.TempDir(), "config"))
 			if err != nil {
-				t.Errorf("Unable to execute test : %v", err)
+				t.Fatalf("Unable to execute test : %v", err)
 			}
+			defer tempFile.Close()
+			tempFile.WriteString(c.content)
 
-			UpdateConfig(*tempFile, c.timeNow)
+			if err := UpdateConfig(*tempFile, c.timeNow); err != nil {
+				t.Fatal(err)
+			}
 
 			tempFile.Seek(0, 0)
 			data := make([]byte, 1024)
@@ -187,3 +336,181 @@ func TestConfigRequiresStable(t *testing.T) {
 		})
 	}
 }
+
+func TestConfigPrefersVersionFile(t *testing.T) {
+	cases := []struct {
+		name, content string
+		want          bool
+	}{
+		{"PreferVersionFile not found", "StableOnly: true\n", true},
+		{"PreferVersionFile true", "PreferVersionFile: true\n", true},
+		{"PreferVersionFile false", "StableOnly: true\nPreferVersionFile: false\n", false},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run("Test: "+c.name, func(t *testing.T) {
+			t.Parallel()
+			tempFile, err := os.CreateTemp(t.TempDir(), "config")
+			if err != nil {
+				t.Fatalf("Unable to execute test : %v", err)
+			}
+			defer tempFile.Close()
+			tempFile.WriteString(c.content)
+
+			got, err := ConfigPrefersVersionFile(*tempFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != c.want {
+				t.Errorf("ConfigPrefersVersionFile expected %t got %t", c.want, got)
+			}
+		})
+	}
+}
+
+func TestConfigSearchBoundary(t *testing.T) {
+	tempFile, err := os.CreateTemp(t.TempDir(), "config")
+	if err != nil {
+		t.Fatalf("Unable to execute test : %v", err)
+	}
+	defer tempFile.Close()
+
+	got, err := ConfigSearchBoundary(*tempFile)
+	if err != nil || got != "" {
+		t.Errorf("ConfigSearchBoundary expected no boundary got %q, %v", got, err)
+	}
+
+	tempFile.WriteString("StableOnly: true\nSearchBoundary: /home/user/src\n")
+	got, err = ConfigSearchBoundary(*tempFile)
+	if err != nil || got != "/home/user/src" {
+		t.Errorf("ConfigSearchBoundary expected %q got %q, %v", "/home/user/src", got, err)
+	}
+}
+
+func TestConfigOffline(t *testing.T) {
+	cases := []struct {
+		name, content string
+		want          bool
+	}{
+		{"Offline not found", "StableOnly: true\n", false},
+		{"Offline true", "StableOnly: true\nOffline: true\n", true},
+		{"Offline false", "Offline: false\n", false},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run("Test: "+c.name, func(t *testing.T) {
+			t.Parallel()
+			tempFile, err := os.CreateTemp(t.TempDir(), "config")
+			if err != nil {
+				t.Fatalf("Unable to execute test : %v", err)
+			}
+			defer tempFile.Close()
+			tempFile.WriteString(c.content)
+
+			got, err := ConfigOffline(*tempFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != c.want {
+				t.Errorf("ConfigOffline expected %t got %t", c.want, got)
+			}
+		})
+	}
+}
+
+func TestUpdateConfig_offline(t *testing.T) {
+	SetOffline(true)
+	defer SetOffline(false)
+
+	tempFile, err := os.CreateTemp(t.TempDir(), "config")
+	if err != nil {
+		t.Fatalf("Unable to execute test : %v", err)
+	}
+	defer tempFile.Close()
+	content := "StableOnly: true\nLastUpdate: 1674481203\nAvailableVersions: [1.3.7]\n"
+	tempFile.WriteString(content)
+
+	if err := UpdateConfig(*tempFile); !errors.Is(err, ErrOffline) {
+		t.Errorf("UpdateConfig expected ErrOffline got %v", err)
+	}
+	got, _ := os.ReadFile(tempFile.Name())
+	if string(got) != content {
+		t.Errorf("UpdateConfig expected the configuration to be unchanged got\n%s", got)
+	}
+}
+
+func TestUpdateConfig_failedUpdate(t *testing.T) {
+	defer SetMirrorUrl(hashicorpUrl)
+	SetMirrorUrl("file://" + filepath.ToSlash(filepath.Join(t.TempDir(), "missing")))
+
+	tempFile, err := os.CreateTemp(t.TempDir(), "config")
+	if err != nil {
+		t.Fatalf("Unable to execute test : %v", err)
+	}
+	defer tempFile.Close()
+	content := "StableOnly: true\nLastUpdate: 1674481203\nAvailableVersions: [1.3.7]\nStrategy: oldest\n"
+	tempFile.WriteString(content)
+
+	firstFailure := time.Date(2010, 10, 10, 10, 10, 10, 10, time.UTC)
+	if err := UpdateConfig(*tempFile, firstFailure); err == nil {
+		t.Fatal("UpdateConfig expected an error from the missing release index")
+	}
+	updateErr := UpdateConfig(*tempFile, firstFailure.Add(time.Minute))
+	if updateErr == nil {
+		t.Fatal("UpdateConfig expected an error from the missing release index")
+	}
+	want := content + "LastFailure: 1286705470\nFailedUpdates: 2\nLastError: " + updateErr.Error() + "\nNetworkError: false\n"
+	got, _ := os.ReadFile(tempFile.Name())
+	if string(got) != want {
+		t.Errorf("UpdateConfig expected the available versions to be kept with the failure recorded got\n%s", got)
+	}
+
+	failure, err := ConfigUpdateFailure(*tempFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantFailure := UpdateFailure{Time: time.Unix(1286705470, 0), Count: 2, Error: updateErr.Error()}
+	if failure == nil || *failure != wantFailure {
+		t.Errorf("ConfigUpdateFailure expected %+v got %+v", wantFailure, failure)
+	}
+
+	SetMirrorUrl("file://" + filepath.ToSlash(testMirror(t, "1.5.7", "")))
+	if err := UpdateConfig(*tempFile, firstFailure.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	want = "StableOnly: true\nLastUpdate: 1286709010\nAvailableVersions: [1.5.7]\nStrategy: oldest\n"
+	got, _ = os.ReadFile(tempFile.Name())
+	if string(got) != want {
+		t.Errorf("UpdateConfig expected the failure to be cleared after a successful update got\n%s", got)
+	}
+	if failure, err := ConfigUpdateFailure(*tempFile); failure != nil || err != nil {
+		t.Errorf("ConfigUpdateFailure expected no failure after a successful update got %+v, %v", failure, err)
+	}
+}
+
+func TestUpdateConfig_releaseCacheError(t *testing.T) {
+	defer SetMirrorUrl(hashicorpUrl)
+	SetMirrorUrl("file://" + filepath.ToSlash(testMirror(t, "1.5.7", "")))
+
+	dir := t.TempDir()
+	// a directory in place of releases.json cannot be written
+	os.Mkdir(filepath.Join(dir, ReleaseCacheFile), 0755)
+	tempFile, err := os.Create(filepath.Join(dir, "config"))
+	if err != nil {
+		t.Fatalf("Unable to execute test : %v", err)
+	}
+	defer tempFile.Close()
+	tempFile.WriteString("StableOnly: true\nLastUpdate: 1674481203\nAvailableVersions: [1.3.7]\n")
+
+	err = UpdateConfig(*tempFile, time.Date(2010, 10, 10, 10, 10, 10, 10, time.UTC))
+	if err == nil || !strings.HasPrefix(err.Error(), "unable to store the release index in releases.json") {
+		t.Errorf("UpdateConfig expected an error storing the release index got %v", err)
+	}
+	want := "StableOnly: true\nLastUpdate: 1286705410\nAvailableVersions: [1.5.7]\n"
+	got, _ := os.ReadFile(tempFile.Name())
+	if string(got) != want {
+		t.Errorf("UpdateConfig expected the available versions to be updated got\n%s", got)
+	}
+}
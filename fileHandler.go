@@ -1,65 +1,335 @@
 package versionedTerraform
 
 import (
-	"bufio"
+	"errors"
+	"fmt"
 	"io/fs"
-	"regexp"
+	"path"
 	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	hcljson "github.com/hashicorp/hcl/v2/json"
+	"github.com/zclconf/go-cty/cty"
+)
+
+const (
+	// defaultConstraint is used when no required_version is found
+	defaultConstraint = ">= 0.0.0"
+
+	terraformFileSuffix     = ".tf"
+	terraformJSONFileSuffix = ".tf.json"
+	overrideFileName        = "override"
+	overrideFileSuffix      = "_override"
+	terraformBlockType      = "terraform"
+	requiredVersionArgument = "required_version"
+	moduleBlockType         = "module"
+	moduleSourceArgument    = "source"
+	gitDir                  = ".git"
+)
+
+var (
+	needsStable    bool
+	searchBoundary string
 )
 
-var needsStable bool
+// VersionSource describes where a required_version constraint, or the content of a version file
+// such as .terraform-version, was read from
+type VersionSource struct {
+	File       string
+	Line       int
+	Constraint string
+}
 
 //GetVersionFromFile returns Version pointer and error
-//Iterates through files in current directory and sending to parseVersionFromFile
+//Collects every required_version in the terraform files of the current directory
+//a version must satisfy all of them, as terraform enforces each one
+//local modules outside of fileSystem, such as ../shared when it is rooted at the module by os.DirFS("."),
+//are skipped and listed in Version.Warnings
 //todo this should be (Version) GetVers...
 func GetVersionFromFile(fileSystem fs.FS, versionList []string, needsStableValue bool) (*Version, error) {
+	return GetVersionFromDir(fileSystem, ".", versionList, needsStableValue)
+}
+
+//GetVersionFromFile is like the package level GetVersionFromFile resolving with o
+func (o Options) GetVersionFromFile(fileSystem fs.FS, versionList []string) (*Version, error) {
+	return o.GetVersionFromDir(fileSystem, ".", versionList)
+}
+
+//GetVersionFromDir returns Version pointer and error
+//Like GetVersionFromFile for the directory dir within fileSystem, local child modules
+//and modules installed by terraform init are included so they may be outside of dir
+//the nearest version file such as .terraform-version or .tool-versions, in dir or its parents up to the repository
+//root, a directory containing .git, or the boundary set by SetSearchBoundary, takes precedence over required_version
+//unless disabled by SetPreferVersionFile, then parents are only searched if dir has no required_version
+//Version.Dir is the directory the sources were read from
+func GetVersionFromDir(fileSystem fs.FS, dir string, versionList []string, needsStableValue bool) (*Version, error) {
 	needsStable = needsStableValue
-	var versionFinal Version
-	versionFinal = *NewVersion(">= 0.0.0", versionList)
-	dir, err := fs.ReadDir(fileSystem, ".")
+	return packageOptions(needsStableValue).GetVersionFromDir(fileSystem, dir, versionList)
+}
+
+//GetVersionFromDir is like the package level GetVersionFromDir resolving with o
+func (o Options) GetVersionFromDir(fileSystem fs.FS, dir string, versionList []string) (*Version, error) {
+	dir = path.Clean(dir)
+	sources, warnings, err := readModuleTreeVersions(fileSystem, dir)
 	if err != nil {
-		return &versionFinal, err
+		return &Version{Dir: dir, Warnings: warnings}, err
+	}
+
+	var version *Version
+	versionFile, versionFileDir, err := nearestVersionFile(fileSystem, dir, o.SearchBoundary)
+	switch {
+	case err != nil:
+		version = &Version{Dir: versionFileDir}
+	case versionFile != nil && (!o.PreferRequiredVersion || len(sources) == 0):
+		// keywords such as min-required refer to the required_version of dir
+		version, err = versionFromVersionFile(*versionFile, sources, versionList, o)
+		version.Dir = versionFileDir
+		if len(sources) > 0 {
+			// the version file selects a single version, Matrix reflects what required_version allows
+			version.required, _ = versionFromSources(sources, versionList, o)
+		}
+	case len(sources) > 0:
+		version, err = versionFromSources(sources, versionList, o)
+		version.Dir = dir
+	default:
+		version, err = o.NewVersion(defaultConstraint, versionList)
+		version.Dir = dir
+	}
+	version.Warnings = warnings
+	return version, err
+}
+
+//SetSearchBoundary sets the directory, within the filesystem given to GetVersionFromDir,
+//above which parent directories are not searched for version files, see Options.SearchBoundary
+func SetSearchBoundary(dir string) {
+	searchBoundary = path.Clean(dir)
+}
+
+//nearestVersionFile returns the version file of dir or of its nearest parent which has one and the directory
+//it was found in, only version files are read in parents as their terraform files belong to unrelated modules
+//the file name is relative to dir, returns nil if there is no version file up to the repository root or boundary
+func nearestVersionFile(f fs.FS, dir, boundary string) (*VersionSource, string, error) {
+	for searchDir := dir; ; searchDir = path.Dir(searchDir) {
+		versionFile, err := readVersionFile(f, searchDir)
+		if err != nil || versionFile != nil {
+			if versionFile != nil {
+				versionFile.File = relativePath(dir, path.Join(searchDir, versionFile.File))
+			}
+			return versionFile, searchDir, err
+		}
+		if isSearchBoundary(f, searchDir, boundary) {
+			return nil, dir, nil
+		}
+	}
+}
+
+//isSearchBoundary returns true if the parents of dir should not be searched for version sources
+func isSearchBoundary(f fs.FS, dir, boundary string) bool {
+	if dir == "." || (boundary != "" && dir == path.Clean(boundary)) {
+		return true
+	}
+	// .git is a file rather than a directory in worktrees and submodules
+	_, err := fs.Stat(f, path.Join(dir, gitDir))
+	return err == nil
+}
+
+//GetVersionFromConstraint returns Version pointer and error
+//Resolves a literal constraint string the same way as a required_version found by GetVersionFromFile
+func GetVersionFromConstraint(constraint string, versionList []string, needsStableValue bool) (*Version, error) {
+	needsStable = needsStableValue
+	return packageOptions(needsStableValue).NewVersion(constraint, versionList)
+}
+
+//readDirectoryVersions returns the required_version sources and module calls of every terraform file in dir
+//override files are read last and replace the sources and module sources of the files before them,
+//as terraform merges them
+func readDirectoryVersions(f fs.FS, dir string) ([]VersionSource, map[string]string, error) {
+	var sources []VersionSource
+	var overrideFiles []string
+	modules := map[string]string{}
+	entries, err := fs.ReadDir(f, dir)
+	if err != nil {
+		return sources, modules, err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !isTerraformFile(entry.Name()) || isIgnoredFile(entry.Name()) {
+			continue
+		}
+		if isOverrideFile(entry.Name()) {
+			overrideFiles = append(overrideFiles, entry.Name())
+			continue
+		}
+		fileSources, fileModules, err := readTerraformFile(f, path.Join(dir, entry.Name()))
+		if err != nil {
+			return sources, modules, err
+		}
+		sources = append(sources, fileSources...)
+		for name, source := range fileModules {
+			modules[name] = source
+		}
 	}
 
-	for _, f := range dir {
-		version, isFinished, err := parseVersionFromFile(fileSystem, f.Name(), versionList)
+	for _, overrideFile := range overrideFiles {
+		fileSources, fileModules, err := readTerraformFile(f, path.Join(dir, overrideFile))
 		if err != nil {
-			return &versionFinal, err
+			return sources, modules, err
+		}
+		if len(fileSources) > 0 {
+			sources = fileSources
 		}
-		if isFinished {
-			versionFinal = *version
-			break
+		for name, source := range fileModules {
+			modules[name] = source
 		}
 	}
-	return &versionFinal, nil
+	return sources, modules, nil
 }
 
-//todo same here
-//parseVersionFromFile returns Version pointer, bool, and error
-//bool returns true if required_version is found
-//if required_version is found sends to NewVersion to determine required version for the application
-func parseVersionFromFile(f fs.FS, fileName string, versionList []string) (*Version, bool, error) {
-	fileHandle, err := f.Open(fileName)
-	regex := regexp.MustCompile("required_version\\s+?=")
-	isComment := "^\\s?#"
-	removeQuotes := regexp.MustCompile("\"")
+//versionFromSources intersects the constraints of every source and selects a version satisfying them with opts
+//errors name the file and line of the constraint which could not be parsed or satisfied
+func versionFromSources(sources []VersionSource, versionList []string, opts Options) (*Version, error) {
+	var constraints Constraints
+	for _, source := range sources {
+		sourceConstraints, err := ParseConstraints(source.Constraint)
+		if err != nil {
+			return &Version{Sources: sources}, fmt.Errorf("invalid version constraint in %s line %d: %w",
+				source.File, source.Line, err)
+		}
+		constraints = constraints.Intersect(sourceConstraints)
+	}
+
+	version, err := newVersion(constraints, versionList, opts)
+	version.Sources = sources
 	if err != nil {
-		return &Version{}, false, err
+		return version, sourcesError(version, err)
 	}
-	defer fileHandle.Close()
+	return version, nil
+}
 
-	fileScanner := bufio.NewScanner(fileHandle)
-	fileScanner.Split(bufio.ScanLines)
+//sourcesError wraps err with the sources which could not be satisfied
+//if every source can be satisfied on its own they are reported as mutually unsatisfiable
+func sourcesError(version *Version, err error) error {
+	var unsatisfiable *UnsatisfiableError
+	if !errors.As(err, &unsatisfiable) {
+		return err
+	}
 
-	for fileScanner.Scan() {
-		_line := fileScanner.Text()
-		isComment, _ := regexp.MatchString(isComment, _line)
-		if strings.Contains(_line, "required_version") && !isComment {
-			_line = regex.ReplaceAllString(_line, "")
-			_line = removeQuotes.ReplaceAllString(_line, "")
-			return NewVersion(_line, versionList), true, nil
+	candidates := version.candidateVersions()
+	var described []string
+	for _, source := range version.Sources {
+		sourceConstraints, _ := ParseConstraints(source.Constraint)
+		if _, sourceErr := sourceConstraints.Select(candidates); sourceErr != nil {
+			return fmt.Errorf("required_version in %s line %d: %w", source.File, source.Line, err)
 		}
+		described = append(described, fmt.Sprintf("%q in %s line %d", source.Constraint, source.File, source.Line))
+	}
+	return fmt.Errorf("required_version constraints are mutually unsatisfiable, %s: %w",
+		strings.Join(described, " and "), err)
+}
+
+//isTerraformFile returns true for native (.tf) and JSON (.tf.json) syntax terraform files
+func isTerraformFile(fileName string) bool {
+	return strings.HasSuffix(fileName, terraformFileSuffix) || strings.HasSuffix(fileName, terraformJSONFileSuffix)
+}
+
+//isIgnoredFile returns true for the files terraform does not load, hidden files, editor backups
+//ending in ~ and emacs #autosave# files, i.e. .#main.tf is an emacs lock and not a terraform file
+func isIgnoredFile(fileName string) bool {
+	return strings.HasPrefix(fileName, ".") ||
+		strings.HasSuffix(fileName, "~") ||
+		strings.HasPrefix(fileName, "#") && strings.HasSuffix(fileName, "#")
+}
+
+//isOverrideFile returns true for override.tf, *_override.tf and their JSON syntax equivalents
+func isOverrideFile(fileName string) bool {
+	name := strings.TrimSuffix(strings.TrimSuffix(fileName, terraformJSONFileSuffix), terraformFileSuffix)
+	return name == overrideFileName || strings.HasSuffix(name, overrideFileSuffix)
+}
+
+//readTerraformFile returns a VersionSource for every required_version argument of the terraform blocks
+//in fileName, either native or JSON syntax, and the source of every module block by module name
+func readTerraformFile(f fs.FS, fileName string) ([]VersionSource, map[string]string, error) {
+	var sources []VersionSource
+	modules := map[string]string{}
+	src, err := fs.ReadFile(f, fileName)
+	if err != nil {
+		return sources, modules, err
 	}
 
-	return &Version{}, false, nil
+	var file *hcl.File
+	var diags hcl.Diagnostics
+	if strings.HasSuffix(fileName, terraformJSONFileSuffix) {
+		file, diags = hcljson.Parse(src, fileName)
+	} else {
+		file, diags = hclsyntax.ParseConfig(src, fileName, hcl.Pos{Line: 1, Column: 1})
+	}
+	if diags.HasErrors() {
+		return sources, modules, fmt.Errorf("unable to parse %s: %s", fileName, diags.Error())
+	}
+
+	content, _, diags := file.Body.PartialContent(&hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{
+			{Type: terraformBlockType},
+			{Type: moduleBlockType, LabelNames: []string{"name"}},
+		},
+	})
+	if diags.HasErrors() {
+		return sources, modules, fmt.Errorf("unable to parse %s: %s", fileName, diags.Error())
+	}
+
+	for _, block := range content.Blocks {
+		if block.Type == moduleBlockType {
+			if source, isLiteral := moduleSource(block); isLiteral {
+				modules[block.Labels[0]] = source
+			}
+			continue
+		}
+
+		blockContent, _, diags := block.Body.PartialContent(&hcl.BodySchema{
+			Attributes: []hcl.AttributeSchema{{Name: requiredVersionArgument}},
+		})
+		if diags.HasErrors() {
+			return sources, modules, fmt.Errorf("unable to parse %s: %s", fileName, diags.Error())
+		}
+
+		attribute, isSet := blockContent.Attributes[requiredVersionArgument]
+		if !isSet {
+			continue
+		}
+
+		value, diags := attribute.Expr.Value(nil)
+		if diags.HasErrors() || value.IsNull() || !value.Type().Equals(cty.String) {
+			return sources, modules, fmt.Errorf("required_version in %s line %d must be a string",
+				fileName, attribute.Range.Start.Line)
+		}
+
+		sources = append(sources, VersionSource{
+			File:       fileName,
+			Line:       attribute.Range.Start.Line,
+			Constraint: value.AsString(),
+		})
+	}
+	return sources, modules, nil
+}
+
+//moduleSource returns the source argument of a module block and whether it is a literal string
+func moduleSource(block *hcl.Block) (string, bool) {
+	blockContent, _, diags := block.Body.PartialContent(&hcl.BodySchema{
+		Attributes: []hcl.AttributeSchema{{Name: moduleSourceArgument}},
+	})
+	if diags.HasErrors() {
+		return "", false
+	}
+
+	attribute, isSet := blockContent.Attributes[moduleSourceArgument]
+	if !isSet {
+		return "", false
+	}
+
+	value, diags := attribute.Expr.Value(nil)
+	if diags.HasErrors() || value.IsNull() || !value.Type().Equals(cty.String) {
+		return "", false
+	}
+	return value.AsString(), true
 }
@@ -1,6 +1,10 @@
 package versionedTerraform
 
 import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"testing/fstest"
 )
@@ -24,11 +28,34 @@ resource "aws_mq_broker" "sample" {
 terraform {
  required_version = "~> 0.12.4"
 }
+`
+	commentedFile = `
+# required_version = "0.11.10"
+// required_version = "0.11.10"
+/*
+terraform {
+  required_version = "0.11.10"
+}
+*/
+variable "note" {
+  description = "required_version = \"0.11.10\""
+  default     = <<EOT
+terraform {
+  required_version = "0.11.10"
+}
+EOT
+}
+
+terraform {
+  required_version = (
+    ">= 0.12.0, < 0.13.0"
+  )
+}
 `
 )
 
 func TestFileHandler(t *testing.T) {
-	want := NewVersion("0.12.31", testVersionList())
+	want, _ := NewVersion("0.12.31", testVersionList())
 
 	fs := fstest.MapFS{
 		"main.tf":     {Data: []byte(firstFile)},
@@ -49,7 +76,7 @@ func TestFileHandler(t *testing.T) {
 }
 
 func TestEmptyTerraformVersion(t *testing.T) {
-	want := NewVersion("1.1.11", testVersionList())
+	want, _ := NewVersion("1.1.11", testVersionList())
 
 	fs := fstest.MapFS{"main.tf": {Data: []byte(firstFile)}}
 
@@ -65,3 +92,374 @@ func TestEmptyTerraformVersion(t *testing.T) {
 		t.Errorf("Expected %v, got %v", want.Version, got.Version)
 	}
 }
+
+func TestInvalidTerraformVersion(t *testing.T) {
+	fs := fstest.MapFS{
+		"versions.tf": {Data: []byte("terraform {\n required_version = \"~> 1\"\n}\n")},
+		"backend.tf":  {Data: []byte("terraform {\n required_version = \">= 1.x\"\n}\n")},
+	}
+
+	_, err := GetVersionFromFile(fs, testVersionList(), true)
+	if err == nil {
+		t.Fatal("Expected an error for an invalid required_version")
+	}
+
+	want := `invalid version constraint in backend.tf line 2: invalid version constraint ">= 1.x": unexpected "x" at position 6`
+	if err.Error() != want {
+		t.Errorf("Expected %q, got %q", want, err.Error())
+	}
+}
+
+func TestParseVersionFromFile_hcl(t *testing.T) {
+	fs := fstest.MapFS{
+		"main.tf":   {Data: []byte(commentedFile)},
+		"README.md": {Data: []byte("required_version = \"0.11.10\"")},
+	}
+
+	version, err := GetVersionFromFile(fs, testVersionList(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if version.Version.ToString() != "0.12.31" {
+		t.Errorf("Expected %v, got %v", "0.12.31", version.Version.ToString())
+	}
+	if len(version.Sources) != 1 {
+		t.Fatalf("Expected 1 source, got %+v", version.Sources)
+	}
+	if version.Sources[0].File != "main.tf" || version.Sources[0].Line != 19 {
+		t.Errorf("Expected required_version from main.tf line 19, got %s line %d",
+			version.Sources[0].File, version.Sources[0].Line)
+	}
+}
+
+func TestParseVersionFromFile_notString(t *testing.T) {
+	fs := fstest.MapFS{
+		"versions.tf": {Data: []byte("terraform {\n required_version = var.version\n}\n")},
+	}
+
+	_, err := GetVersionFromFile(fs, testVersionList(), true)
+	want := "required_version in versions.tf line 2 must be a string"
+	if err == nil || err.Error() != want {
+		t.Errorf("Expected %q, got %v", want, err)
+	}
+}
+
+func TestParseVersionFromFile_json(t *testing.T) {
+	cases := []struct {
+		name, content, want string
+		lines               []int
+	}{
+		{"terraform object",
+			`{"terraform": {"required_version": "~> 0.12.4"}}`,
+			"0.12.31", []int{1}},
+		{"terraform array",
+			`{
+  "//": "generated, required_version = 0.11.10",
+  "terraform": [
+    {"backend": {"local": {}}},
+    {"required_version": ">= 0.13.0, < 1.0.0"}
+  ]
+}`,
+			"0.14.0", []int{5}},
+		{"multiple required_version",
+			`{"terraform": [{"required_version": ">= 0.12"}, {"required_version": "< 0.13"}]}`,
+			"0.12.31", []int{1, 1}},
+	}
+
+	for _, c := range cases {
+		t.Run("Test: "+c.name, func(t *testing.T) {
+			fs := fstest.MapFS{
+				"main.tf.json": {Data: []byte(c.content)},
+			}
+
+			version, err := GetVersionFromFile(fs, testVersionList(), true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if version.Version.ToString() != c.want {
+				t.Errorf("Expected %v, got %v", c.want, version.Version.ToString())
+			}
+			if len(version.Sources) != len(c.lines) {
+				t.Fatalf("Expected %d sources, got %+v", len(c.lines), version.Sources)
+			}
+			for i, source := range version.Sources {
+				if source.File != "main.tf.json" || source.Line != c.lines[i] {
+					t.Errorf("Expected required_version from main.tf.json line %d, got %+v", c.lines[i], source)
+				}
+			}
+		})
+	}
+}
+
+func TestGetVersionFromFile_intersect(t *testing.T) {
+	fs := fstest.MapFS{
+		"backend.tf":  {Data: []byte("terraform {\n  backend \"local\" {}\n  required_version = \"< 0.13\"\n}\n")},
+		"main.tf":     {Data: []byte(firstFile)},
+		"versions.tf": {Data: []byte("terraform {\n  required_version = \">= 0.12.0\"\n}\n")},
+	}
+
+	version, err := GetVersionFromFile(fs, testVersionList(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version.Version.ToString() != "0.12.31" {
+		t.Errorf("Expected %v, got %v", "0.12.31", version.Version.ToString())
+	}
+
+	clauses := version.Explain().Clauses
+	if len(clauses) != 2 {
+		t.Fatalf("Expected 2 clauses, got %+v", clauses)
+	}
+	want := map[string]string{"< 0.13": "backend.tf", ">= 0.12.0": "versions.tf"}
+	for _, clause := range clauses {
+		if len(clause.Sources) != 1 || clause.Sources[0].File != want[clause.Constraint.String()] {
+			t.Errorf("Expected clause %q from %s, got %+v",
+				clause.Constraint.String(), want[clause.Constraint.String()], clause.Sources)
+		}
+	}
+}
+
+func TestGetVersionFromFile_ignoredFiles(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "versions.tf"), []byte("terraform {\n  required_version = \">= 0.12.0\"\n}\n"), 0644)
+	os.WriteFile(filepath.Join(dir, ".versions.tf"), []byte("terraform {\n  required_version = \"< 0.12\"\n}\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "#main.tf#"), []byte("terraform {\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "main.tf~"), []byte("terraform {\n"), 0644)
+	// emacs locks a file being edited with a dangling symlink
+	if err := os.Symlink("user@host.1234:1697000000", filepath.Join(dir, ".#main.tf")); err != nil {
+		t.Skipf("Unable to create a symlink: %v", err)
+	}
+
+	version, err := GetVersionFromFile(os.DirFS(dir), testVersionList(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version.Version.ToString() != "1.1.11" {
+		t.Errorf("Expected %v, got %v", "1.1.11", version.Version.ToString())
+	}
+	if len(version.Sources) != 1 || version.Sources[0].File != "versions.tf" {
+		t.Errorf("Expected only the source of versions.tf, got %+v", version.Sources)
+	}
+}
+
+func TestGetVersionFromFile_mutuallyUnsatisfiable(t *testing.T) {
+	fs := fstest.MapFS{
+		"backend.tf":  {Data: []byte("terraform {\n  required_version = \"< 0.13\"\n}\n")},
+		"versions.tf": {Data: []byte("terraform {\n  required_version = \"~> 1.1.0\"\n}\n")},
+	}
+
+	_, err := GetVersionFromFile(fs, testVersionList(), true)
+	var unsatisfiable *UnsatisfiableError
+	if !errors.As(err, &unsatisfiable) {
+		t.Fatalf("Expected an UnsatisfiableError, got %v", err)
+	}
+
+	want := `required_version constraints are mutually unsatisfiable, "< 0.13" in backend.tf line 2 and "~> 1.1.0" in versions.tf line 2: `
+	if !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("Expected %q to start with %q", err.Error(), want)
+	}
+}
+
+func TestGetVersionFromFile_unsatisfiableSource(t *testing.T) {
+	fs := fstest.MapFS{
+		"backend.tf":  {Data: []byte("terraform {\n  required_version = \"< 0.13\"\n}\n")},
+		"versions.tf": {Data: []byte("terraform {\n  required_version = \">= 9.0\"\n}\n")},
+	}
+
+	_, err := GetVersionFromFile(fs, testVersionList(), true)
+	want := `required_version in versions.tf line 2: `
+	if err == nil || !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("Expected %v to start with %q", err, want)
+	}
+}
+
+func TestGetVersionFromFile_override(t *testing.T) {
+	cases := []struct {
+		name  string
+		files fstest.MapFS
+		want  string
+	}{
+		{"override.tf replaces base constraints",
+			fstest.MapFS{
+				"versions.tf": {Data: []byte("terraform {\n  required_version = \"~> 0.12.0\"\n}\n")},
+				"backend.tf":  {Data: []byte("terraform {\n  required_version = \"< 0.12.31\"\n}\n")},
+				"override.tf": {Data: []byte("terraform {\n  required_version = \">= 0.13\"\n}\n")},
+			},
+			"1.1.11"},
+		{"later override files replace earlier ones",
+			fstest.MapFS{
+				"versions.tf":           {Data: []byte("terraform {\n  required_version = \"~> 0.12.0\"\n}\n")},
+				"a_override.tf":         {Data: []byte("terraform {\n  required_version = \">= 0.13\"\n}\n")},
+				"b_override.tf.json":    {Data: []byte(`{"terraform": {"required_version": "~> 1.0.0"}}`)},
+				"aa_notoverride.tf.bak": {Data: []byte("terraform {\n  required_version = \"0.11.10\"\n}\n")},
+			},
+			"1.0.12"},
+		{"override without required_version keeps base constraints",
+			fstest.MapFS{
+				"versions.tf":         {Data: []byte("terraform {\n  required_version = \"~> 0.12.0\"\n}\n")},
+				"backend_override.tf": {Data: []byte("terraform {\n  backend \"local\" {}\n}\n")},
+			},
+			"0.12.31"},
+	}
+
+	for _, c := range cases {
+		t.Run("Test: "+c.name, func(t *testing.T) {
+			version, err := GetVersionFromFile(c.files, testVersionList(), true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if version.Version.ToString() != c.want {
+				t.Errorf("Expected %v, got %v", c.want, version.Version.ToString())
+			}
+		})
+	}
+}
+
+func TestGetVersionFromDir_parentDirectories(t *testing.T) {
+	fs := fstest.MapFS{
+		"repo/.git/HEAD":                   {Data: []byte("ref: refs/heads/main\n")},
+		"repo/.terraform-version":          {Data: []byte("1.0.1\n")},
+		"repo/scripts/run.sh":              {Data: []byte("#!/bin/sh\n")},
+		"repo/stacks/network/versions.tf":  {Data: []byte(secondFile)},
+		"repo/stacks/network/sub/notes.md": {Data: []byte("notes\n")},
+		"repo/stacks/broken.tf":            {Data: []byte("terraform {\n")},
+		"other/.terraform-version":         {Data: []byte("1.0.12\n")},
+		"other/nested/deeper/main.tf":      {Data: []byte(firstFile)},
+		".terraform-version":               {Data: []byte("0.13.0\n")},
+		"outside/repo/.git":                {Data: []byte("gitdir: ../../.git/worktrees/repo\n")},
+		"outside/repo/app/main.tf":         {Data: []byte(firstFile)},
+		"outside/repo/lib/versions.tf":     {Data: []byte(secondFile)},
+		"keyword/.git/HEAD":                {Data: []byte("ref: refs/heads/main\n")},
+		"keyword/.terraform-version":       {Data: []byte("min-required\n")},
+		"keyword/app/versions.tf":          {Data: []byte(secondFile)},
+	}
+
+	cases := []struct {
+		dir, want, wantDir, wantFile string
+	}{
+		{"repo/scripts", "1.0.1", "repo", "../.terraform-version"},
+		{"repo/stacks/network/sub", "1.0.1", "repo", "../../../.terraform-version"},
+		{"repo/stacks/network", "1.0.1", "repo", "../../.terraform-version"},
+		{"repo", "1.0.1", "repo", ".terraform-version"},
+		{"other/nested/deeper", "1.0.12", "other", "../../.terraform-version"},
+		{"outside/repo/app", "1.1.11", "outside/repo/app", ""},
+		{"outside/repo/lib", "0.12.31", "outside/repo/lib", "versions.tf"},
+		{"keyword/app", "0.12.30", "keyword", "../.terraform-version"},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run("test parent directories: "+c.dir, func(t *testing.T) {
+			got, err := GetVersionFromDir(fs, c.dir, testVersionList(), true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.VersionToString() != c.want {
+				t.Errorf("Expected %q, got %q", c.want, got.VersionToString())
+			}
+			if got.Dir != c.wantDir {
+				t.Errorf("Expected the sources of %q, got %q", c.wantDir, got.Dir)
+			}
+			if c.wantFile != "" && (len(got.Sources) == 0 || got.Sources[0].File != c.wantFile) {
+				t.Errorf("Expected a source from %q, got %+v", c.wantFile, got.Sources)
+			}
+		})
+	}
+}
+
+func TestGetVersionFromDir_parentDirectoriesPreferRequiredVersion(t *testing.T) {
+	SetPreferVersionFile(false)
+	defer SetPreferVersionFile(true)
+
+	fs := fstest.MapFS{
+		"repo/.git/HEAD":                  {Data: []byte("ref: refs/heads/main\n")},
+		"repo/.terraform-version":         {Data: []byte("1.0.1\n")},
+		"repo/scripts/run.sh":             {Data: []byte("#!/bin/sh\n")},
+		"repo/stacks/network/versions.tf": {Data: []byte(secondFile)},
+	}
+
+	cases := []struct {
+		dir, want, wantDir string
+	}{
+		{"repo/stacks/network", "0.12.31", "repo/stacks/network"},
+		{"repo/scripts", "1.0.1", "repo"},
+	}
+
+	for _, c := range cases {
+		got, err := GetVersionFromDir(fs, c.dir, testVersionList(), true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.VersionToString() != c.want || got.Dir != c.wantDir {
+			t.Errorf("Expected %q from %q, got %q from %q", c.want, c.wantDir, got.VersionToString(), got.Dir)
+		}
+	}
+}
+
+func TestGetVersionFromDir_searchBoundary(t *testing.T) {
+	SetSearchBoundary("monorepo/stacks")
+	defer SetSearchBoundary(".")
+
+	fs := fstest.MapFS{
+		"monorepo/.terraform-version":       {Data: []byte("1.0.1\n")},
+		"monorepo/stacks/app/main.tf":       {Data: []byte(firstFile)},
+		"monorepo/tools/.terraform-version": {Data: []byte("0.13.0\n")},
+		"monorepo/tools/bin/run.sh":         {Data: []byte("#!/bin/sh\n")},
+	}
+
+	got, err := GetVersionFromDir(fs, "monorepo/stacks/app", testVersionList(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.VersionToString() != "1.1.11" || got.Dir != "monorepo/stacks/app" {
+		t.Errorf("Expected the search to stop at the boundary, got %q from %q", got.VersionToString(), got.Dir)
+	}
+
+	got, err = GetVersionFromDir(fs, "monorepo/tools/bin", testVersionList(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.VersionToString() != "0.13.0" || got.Dir != "monorepo/tools" {
+		t.Errorf("Expected 0.13.0 from monorepo/tools, got %q from %q", got.VersionToString(), got.Dir)
+	}
+}
+
+func TestOptions_GetVersionFromDir(t *testing.T) {
+	fs := fstest.MapFS{
+		"monorepo/.terraform-version":   {Data: []byte("1.0.1\n")},
+		"monorepo/stacks/app/main.tf":   {Data: []byte(firstFile)},
+		"monorepo/stacks/app/.git/HEAD": {Data: []byte("ref: refs/heads/main\n")},
+		"monorepo/stacks/lib/main.tf":   {Data: []byte("terraform {\n  required_version = \"~> 1.1.0\"\n}\n")},
+	}
+
+	// each configuration is resolved with its own Options, without package state
+	cases := []struct {
+		name, dir      string
+		opts           Options
+		expected, from string
+	}{
+		{"version file", "monorepo/stacks/lib", Options{StableOnly: true}, "1.0.1", "monorepo"},
+		{"required_version preferred", "monorepo/stacks/lib",
+			Options{StableOnly: true, PreferRequiredVersion: true}, "1.1.11", "monorepo/stacks/lib"},
+		{"oldest", "monorepo/stacks/lib",
+			Options{Strategy: StrategyOldest, StableOnly: true, PreferRequiredVersion: true}, "1.1.1", "monorepo/stacks/lib"},
+		{"repository root", "monorepo/stacks/app", Options{StableOnly: true}, "1.1.11", "monorepo/stacks/app"},
+		{"search boundary", "monorepo/stacks/lib",
+			Options{StableOnly: true, SearchBoundary: "monorepo/stacks"}, "1.1.11", "monorepo/stacks/lib"},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run("Test: "+c.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := c.opts.GetVersionFromDir(fs, c.dir, testVersionList())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.VersionToString() != c.expected || got.Dir != c.from {
+				t.Errorf("Expected %q from %q, got %q from %q", c.expected, c.from, got.VersionToString(), got.Dir)
+			}
+		})
+	}
+}
@@ -1,5 +1,16 @@
 module versionedTerraform
 
-go 1.17
+go 1.18
 
-require github.com/blang/semver/v4 v4.0.0
+require (
+	github.com/hashicorp/hcl/v2 v2.19.1
+	github.com/zclconf/go-cty v1.13.0
+)
+
+require (
+	github.com/agext/levenshtein v1.2.1 // indirect
+	github.com/apparentlymart/go-textseg/v13 v13.0.0 // indirect
+	github.com/apparentlymart/go-textseg/v15 v15.0.0 // indirect
+	github.com/mitchellh/go-wordwrap v0.0.0-20150314170334-ad45545899c7 // indirect
+	golang.org/x/text v0.11.0 // indirect
+)
@@ -0,0 +1,177 @@
+package versionedTerraform
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+const (
+	httpScheme  = "http"
+	httpsScheme = "https"
+	fileScheme  = "file"
+)
+
+const (
+	versionPlaceholder = "{version}"
+	osPlaceholder      = "{os}"
+	archPlaceholder    = "{arch}"
+	suffixPlaceholder  = "{suffix}"
+)
+
+var (
+	// releaseBaseUrl is the root of the releases.hashicorp.com/terraform/ layout versions are fetched from
+	releaseBaseUrl = hashicorpUrl
+	// indexUrl, downloadUrlTemplate and checksumUrlTemplate replace the location of the
+	// release index, archives and checksum files within releaseBaseUrl when set
+	indexUrl            string
+	downloadUrlTemplate string
+	checksumUrlTemplate string
+)
+
+var urlPlaceholder = regexp.MustCompile(`\{[^}]*\}`)
+
+// SetMirrorUrl sets the root the release index and downloads are fetched from in place of
+// releases.hashicorp.com/terraform/, mirrorUrl must follow the same layout and may be an
+// http://, https:// or file:// URL
+func SetMirrorUrl(mirrorUrl string) error {
+	if err := validateUrl("mirror URL", mirrorUrl); err != nil {
+		return err
+	}
+	if !strings.HasSuffix(mirrorUrl, "/") {
+		mirrorUrl += "/"
+	}
+	releaseBaseUrl = mirrorUrl
+	return nil
+}
+
+// SetUrlTemplates sets the locations of the release index, archives and checksum files for mirrors which do
+// not follow the releases.hashicorp.com layout, an empty value keeps the location within the mirror
+// download and checksum may contain the placeholders {version}, {os}, {arch} and {suffix}, i.e.
+// https://mirror.example.com/tools/terraform/{version}/terraform-{os}-{arch}.zip
+// where {suffix} is the end of hashicorp's archive name, i.e. _linux_amd64.zip
+func SetUrlTemplates(index string, download string, checksum string) error {
+	for _, template := range []struct{ name, value string }{
+		{"index URL", index},
+		{"download URL template", download},
+		{"checksum URL template", checksum},
+	} {
+		if template.value == "" {
+			continue
+		}
+		if err := validateUrl(template.name, template.value); err != nil {
+			return err
+		}
+		for _, placeholder := range urlPlaceholder.FindAllString(template.value, -1) {
+			switch placeholder {
+			case versionPlaceholder, osPlaceholder, archPlaceholder, suffixPlaceholder:
+			default:
+				return fmt.Errorf("invalid %s %q: unknown placeholder %s, expected %s, %s, %s or %s", template.name,
+					template.value, placeholder, versionPlaceholder, osPlaceholder, archPlaceholder, suffixPlaceholder)
+			}
+		}
+	}
+
+	indexUrl = index
+	downloadUrlTemplate = download
+	checksumUrlTemplate = checksum
+	return nil
+}
+
+// validateUrl returns an error if rawUrl is not an http://, https:// or file:// URL
+func validateUrl(name string, rawUrl string) error {
+	parsed, err := url.Parse(rawUrl)
+	if err != nil {
+		return fmt.Errorf("invalid %s %q: %w", name, rawUrl, err)
+	}
+	switch parsed.Scheme {
+	case httpScheme, httpsScheme, fileScheme:
+		return nil
+	}
+	return fmt.Errorf("invalid %s %q: expected an http://, https:// or file:// URL", name, rawUrl)
+}
+
+// releaseIndexUrl returns the URL the release index is fetched from
+func releaseIndexUrl() string {
+	if indexUrl != "" {
+		return indexUrl
+	}
+	return releaseBaseUrl + releaseIndexFile
+}
+
+// expandUrlTemplate replaces the placeholders of template for version and the platform of the
+// archive ending in suffix, i.e. _darwin_amd64.zip
+func expandUrlTemplate(template string, version string, suffix string) string {
+	platform := strings.Split(strings.TrimSuffix(strings.TrimPrefix(suffix, "_"), path.Ext(suffix)), "_")
+	goos, goarch := runtime.GOOS, runtime.GOARCH
+	if len(platform) == 2 {
+		goos, goarch = platform[0], platform[1]
+	}
+	return strings.NewReplacer(
+		versionPlaceholder, version,
+		osPlaceholder, goos,
+		archPlaceholder, goarch,
+		suffixPlaceholder, suffix,
+	).Replace(template)
+}
+
+// findBuild returns the Release of version and its Build archived with suffix
+// the download and checksum URL templates replace the locations listed in the release index, which is
+// only required to check the platform when a download URL template is not set
+func findBuild(configDir string, version string, suffix string) (Release, Build, error) {
+	filename := terraformPrefix + version + suffix
+	release, err := findRelease(configDir, version)
+	if err != nil && downloadUrlTemplate == "" {
+		return release, Build{}, err
+	}
+	if err != nil {
+		// a mirror with its own layout may not publish a release index
+		release = Release{Version: version}
+	}
+
+	var build Build
+	if len(release.Builds) > 0 || downloadUrlTemplate == "" {
+		if build, err = release.build(filename); err != nil {
+			return release, build, err
+		}
+	}
+	if downloadUrlTemplate != "" {
+		build.Filename = filename
+		build.URL = expandUrlTemplate(downloadUrlTemplate, version, suffix)
+	}
+	if checksumUrlTemplate != "" {
+		release.ShasumsURL = expandUrlTemplate(checksumUrlTemplate, version, suffix)
+	}
+	return release, build, nil
+}
+
+// fetch returns the content of an http://, https:// or file:// URL, only file:// URLs are read in offline mode
+func fetch(rawUrl string) ([]byte, error) {
+	parsed, err := url.Parse(rawUrl)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Scheme == fileScheme {
+		return os.ReadFile(filepath.FromSlash(parsed.Path))
+	}
+	if offline {
+		return nil, ErrOffline
+	}
+
+	resp, err := http.Get(rawUrl)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", rawUrl, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
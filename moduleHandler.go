@@ -0,0 +1,163 @@
+package versionedTerraform
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	modulesManifest = ".terraform/modules/modules.json"
+	localSourceDot  = "./"
+	localSourceUp   = "../"
+)
+
+// modulesManifestFile is the part of .terraform/modules/modules.json written by terraform init
+// which records where each installed module was placed
+type modulesManifestFile struct {
+	Modules []struct {
+		Key    string `json:"Key"`
+		Source string `json:"Source"`
+		Dir    string `json:"Dir"`
+	} `json:"Modules"`
+}
+
+// moduleTree records the directories read and the modules skipped while reading a module tree
+type moduleTree struct {
+	visited  map[string]bool
+	warnings []string
+}
+
+//readModuleTreeVersions returns the required_version sources of the root module in dir,
+//of local child modules called from it recursively and of the modules installed by terraform init
+//and a warning for every local module which could not be read as it is outside of the file system
+//source file names are relative to dir
+func readModuleTreeVersions(f fs.FS, dir string) ([]VersionSource, []string, error) {
+	dir = path.Clean(dir)
+	tree := &moduleTree{visited: map[string]bool{}}
+	sources, err := readModuleVersions(f, dir, tree)
+	if err != nil {
+		return sources, tree.warnings, err
+	}
+
+	installedDirs, err := readModulesManifest(f, dir)
+	if err != nil {
+		return sources, tree.warnings, err
+	}
+	for _, installedDir := range installedDirs {
+		installedSources, err := readModuleVersions(f, installedDir, tree)
+		if errors.Is(err, fs.ErrNotExist) {
+			// the manifest is stale, terraform init will install the module again
+			continue
+		}
+		if err != nil {
+			return sources, tree.warnings, err
+		}
+		sources = append(sources, installedSources...)
+	}
+
+	for i := range sources {
+		sources[i].File = relativePath(dir, sources[i].File)
+	}
+	return sources, tree.warnings, nil
+}
+
+//readModuleVersions returns the required_version sources of the module in dir
+//and of every local child module it calls, each directory is only read once
+func readModuleVersions(f fs.FS, dir string, tree *moduleTree) ([]VersionSource, error) {
+	if tree.visited[dir] {
+		return nil, nil
+	}
+	tree.visited[dir] = true
+
+	sources, modules, err := readDirectoryVersions(f, dir)
+	if err != nil {
+		return sources, err
+	}
+
+	var names []string
+	for name := range modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		source := modules[name]
+		if !isLocalModuleSource(source) {
+			continue
+		}
+		childDir := path.Join(dir, source)
+		if !fs.ValidPath(childDir) {
+			// i.e. a sibling module when the file system is rooted at the module, as with os.DirFS(".")
+			tree.warnings = append(tree.warnings, fmt.Sprintf(
+				"module %q is skipped: source %q is outside of the searched directory", name, source))
+			continue
+		}
+		childSources, err := readModuleVersions(f, childDir, tree)
+		if err != nil {
+			return sources, fmt.Errorf("unable to read module %q: %w", name, err)
+		}
+		sources = append(sources, childSources...)
+	}
+	return sources, nil
+}
+
+//readModulesManifest returns the directories of the modules installed by terraform init for the root module in dir
+//returns no directories if terraform init has not been run
+func readModulesManifest(f fs.FS, dir string) ([]string, error) {
+	var dirs []string
+	data, err := fs.ReadFile(f, path.Join(dir, modulesManifest))
+	if errors.Is(err, fs.ErrNotExist) {
+		return dirs, nil
+	}
+	if err != nil {
+		return dirs, err
+	}
+
+	var manifest modulesManifestFile
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return dirs, fmt.Errorf("unable to parse %s: %w", modulesManifest, err)
+	}
+
+	for _, module := range manifest.Modules {
+		// the root module is recorded with an empty key
+		if module.Key == "" || module.Dir == "" {
+			continue
+		}
+		moduleDir := path.Join(dir, strings.ReplaceAll(module.Dir, "\\", "/"))
+		if fs.ValidPath(moduleDir) {
+			dirs = append(dirs, moduleDir)
+		}
+	}
+	return dirs, nil
+}
+
+//isLocalModuleSource returns true for module sources which are paths on the local filesystem
+func isLocalModuleSource(source string) bool {
+	return strings.HasPrefix(source, localSourceDot) || strings.HasPrefix(source, localSourceUp)
+}
+
+//relativePath returns target relative to base, both slash separated paths within the same fs.FS
+func relativePath(base string, target string) string {
+	if base == "." {
+		return target
+	}
+	baseElements := strings.Split(base, "/")
+	targetElements := strings.Split(target, "/")
+
+	common := 0
+	for common < len(baseElements) && common < len(targetElements)-1 && baseElements[common] == targetElements[common] {
+		common++
+	}
+
+	var relative []string
+	for i := common; i < len(baseElements); i++ {
+		relative = append(relative, "..")
+	}
+	relative = append(relative, targetElements[common:]...)
+	return strings.Join(relative, "/")
+}
@@ -0,0 +1,117 @@
+package versionedTerraform
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestGetVersionFromDir_modules(t *testing.T) {
+	fs := fstest.MapFS{
+		"envs/prod/main.tf": {Data: []byte(`
+module "network" {
+  source = "./modules/network"
+}
+module "shared" {
+  source = "../../shared"
+}
+module "registry" {
+  source  = "terraform-aws-modules/vpc/aws"
+  version = "~> 3.0"
+}
+`)},
+		"envs/prod/versions.tf":                    {Data: []byte("terraform {\n  required_version = \">= 0.12\"\n}\n")},
+		"envs/prod/modules/network/versions.tf":    {Data: []byte("terraform {\n  required_version = \"< 1.1\"\n}\n")},
+		"envs/prod/modules/network/main.tf":        {Data: []byte("module \"self\" {\n  source = \"../network\"\n}\n")},
+		"shared/versions.tf":                       {Data: []byte("terraform {\n  required_version = \"!= 1.0.12\"\n}\n")},
+		"envs/prod/.terraform/modules/vpc/main.tf": {Data: []byte("terraform {\n  required_version = \"~> 1.0.0\"\n}\n")},
+		"envs/prod/.terraform/modules/modules.json": {Data: []byte(`{"Modules":[
+  {"Key":"","Source":"","Dir":"."},
+  {"Key":"network","Source":"./modules/network","Dir":"modules/network"},
+  {"Key":"registry","Source":"registry.terraform.io/terraform-aws-modules/vpc/aws","Version":"3.19.0","Dir":".terraform/modules/vpc"},
+  {"Key":"removed","Source":"registry.terraform.io/example/removed/aws","Version":"1.0.0","Dir":".terraform/modules/removed"}
+]}`)},
+	}
+
+	version, err := GetVersionFromDir(fs, "envs/prod", testVersionList(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version.Version.ToString() != "1.0.1" {
+		t.Errorf("Expected %v, got %v", "1.0.1", version.Version.ToString())
+	}
+
+	var files []string
+	for _, source := range version.Sources {
+		files = append(files, source.File)
+	}
+	want := "versions.tf modules/network/versions.tf ../../shared/versions.tf .terraform/modules/vpc/main.tf"
+	if strings.Join(files, " ") != want {
+		t.Errorf("Expected sources %q, got %q", want, strings.Join(files, " "))
+	}
+}
+
+func TestGetVersionFromDir_moduleErrors(t *testing.T) {
+	cases := []struct {
+		name  string
+		files fstest.MapFS
+		want  string
+	}{
+		{"missing local module",
+			fstest.MapFS{"main.tf": {Data: []byte("module \"network\" {\n  source = \"./modules/network\"\n}\n")}},
+			`unable to read module "network": open modules/network: file does not exist`},
+		{"invalid modules.json",
+			fstest.MapFS{".terraform/modules/modules.json": {Data: []byte("{")}},
+			"unable to parse .terraform/modules/modules.json: unexpected end of JSON input"},
+	}
+
+	for _, c := range cases {
+		t.Run("Test: "+c.name, func(t *testing.T) {
+			_, err := GetVersionFromDir(c.files, ".", testVersionList(), true)
+			if err == nil || err.Error() != c.want {
+				t.Errorf("Expected %q, got %v", c.want, err)
+			}
+		})
+	}
+}
+
+func TestGetVersionFromFile_moduleOutsideFileSystem(t *testing.T) {
+	fs := fstest.MapFS{
+		"main.tf": {Data: []byte("module \"shared\" {\n  source = \"../shared\"\n}\n" +
+			"terraform {\n  required_version = \"~> 1.0.0\"\n}\n")},
+	}
+
+	version, err := GetVersionFromFile(fs, testVersionList(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version.Version.ToString() != "1.0.12" {
+		t.Errorf("Expected %v, got %v", "1.0.12", version.Version.ToString())
+	}
+	want := `module "shared" is skipped: source "../shared" is outside of the searched directory`
+	if len(version.Warnings) != 1 || version.Warnings[0] != want {
+		t.Errorf("Expected warnings [%s], got %v", want, version.Warnings)
+	}
+}
+
+func TestRelativePath(t *testing.T) {
+	cases := []struct {
+		base, target, want string
+	}{
+		{".", "versions.tf", "versions.tf"},
+		{"envs/prod", "envs/prod/versions.tf", "versions.tf"},
+		{"envs/prod", "envs/prod/modules/a/versions.tf", "modules/a/versions.tf"},
+		{"envs/prod", "envs/shared/versions.tf", "../shared/versions.tf"},
+		{"envs/prod", "shared/versions.tf", "../../shared/versions.tf"},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run("Test: "+c.target, func(t *testing.T) {
+			t.Parallel()
+			if got := relativePath(c.base, c.target); got != c.want {
+				t.Errorf("Expected %q, got %q", c.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package versionedTerraform
+
+import (
+	"errors"
+	"net"
+)
+
+// ErrOffline is returned instead of fetching from the network in offline mode
+var ErrOffline = errors.New("offline mode is enabled, the network is not used")
+
+var offline bool
+
+// SetOffline sets whether the release index and downloads may be fetched from the network
+// file:// mirrors are still read in offline mode
+func SetOffline(isOffline bool) {
+	offline = isOffline
+}
+
+// IsNetworkError returns true if err was caused by the network being unreachable or by offline mode
+// errors connecting, resolving the host or timing out are network errors, a malformed URL or a file:// mirror
+// which cannot be read are not
+func IsNetworkError(err error) bool {
+	var opError *net.OpError
+	var dnsError *net.DNSError
+	var netError net.Error
+	if errors.Is(err, ErrOffline) || errors.As(err, &opError) || errors.As(err, &dnsError) {
+		return true
+	}
+	// url.Error and syscall.Errno also implement net.Error, only their timeouts are network errors
+	return errors.As(err, &netError) && netError.Timeout()
+}
@@ -0,0 +1,65 @@
+package versionedTerraform
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestOffline_fetch(t *testing.T) {
+	SetOffline(true)
+	defer SetOffline(false)
+	defer SetMirrorUrl(hashicorpUrl)
+
+	SetMirrorUrl("https://mirror.invalid/terraform/")
+	_, err := GetVersionList()
+	if !errors.Is(err, ErrOffline) {
+		t.Errorf("Expected ErrOffline, got %v", err)
+	}
+
+	SetMirrorUrl("file://" + filepath.ToSlash(testMirror(t, "1.5.7", "")))
+	versions, err := GetVersionList()
+	if err != nil || len(versions) != 1 {
+		t.Errorf("Expected file:// mirrors to be read offline, got %v, %v", versions, err)
+	}
+}
+
+func TestIsNetworkError(t *testing.T) {
+	_, unreachable := fetch("http://127.0.0.1:1/terraform/index.json")
+	_, missing := fetch("file://" + filepath.ToSlash(filepath.Join(t.TempDir(), "index.json")))
+	_, malformed := fetch("http://mirror.example.com/%zz/index.json")
+	if malformed == nil {
+		t.Fatal("Expected an error fetching a malformed URL")
+	}
+
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"offline", fmt.Errorf("failed to download Terraform: %w", ErrOffline), true},
+		{"unreachable", fmt.Errorf("unable to fetch the release index: %w", unreachable), true},
+		{"missing file:// mirror", fmt.Errorf("unable to fetch the release index: %w", missing), false},
+		{"malformed URL", fmt.Errorf("unable to fetch the release index: %w", malformed), false},
+		{"timeout", fmt.Errorf("unable to fetch the release index: %w", timeoutError{}), true},
+		{"checksum mismatch", errors.New("checksum mismatch for terraform_1.5.7_linux_amd64.zip"), false},
+		{"no error", nil, false},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run("test network error: "+c.name, func(t *testing.T) {
+			if got := IsNetworkError(c.err); got != c.want {
+				t.Errorf("Expected %t, got %t for %v", c.want, got, c.err)
+			}
+		})
+	}
+}
+
+// timeoutError is a net.Error reporting a timeout
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
@@ -0,0 +1,169 @@
+package versionedTerraform
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	releaseIndexFile = "index.json"
+	// ReleaseCacheFile is the file in the configuration directory the release index is stored in
+	ReleaseCacheFile = "releases.json"
+)
+
+// ReleaseIndex is the catalog of terraform releases published in the release index
+// BaseUrl is the root the index was fetched from
+type ReleaseIndex struct {
+	BaseUrl  string             `json:"base_url"`
+	Versions map[string]Release `json:"versions"`
+}
+
+// Release is a single version of terraform with its builds and the location of their checksums
+type Release struct {
+	Version          string  `json:"version"`
+	Shasums          string  `json:"shasums"`
+	ShasumsSignature string  `json:"shasums_signature"`
+	ShasumsURL       string  `json:"shasums_url"`
+	SignatureURL     string  `json:"shasums_signature_url"`
+	Builds           []Build `json:"builds"`
+}
+
+// Build is the archive of a Release for a single platform
+type Build struct {
+	OS       string `json:"os"`
+	Arch     string `json:"arch"`
+	Filename string `json:"filename"`
+	URL      string `json:"url"`
+}
+
+// GetReleaseIndex returns the catalog of terraform releases from hashicorp's release index
+// or the mirror and index URL set by SetMirrorUrl and SetUrlTemplates
+func GetReleaseIndex() (ReleaseIndex, error) {
+	data, err := fetch(releaseIndexUrl())
+	if err != nil {
+		return ReleaseIndex{}, fmt.Errorf("unable to fetch the release index: %w", err)
+	}
+	return parseReleaseIndex(data, releaseBaseUrl)
+}
+
+// parseReleaseIndex returns the ReleaseIndex in data, build, checksum and signature URLs are resolved
+// against baseUrl as the index of a mirror may still list hashicorp's URLs
+// releases which are not valid versions are left out
+func parseReleaseIndex(data []byte, baseUrl string) (ReleaseIndex, error) {
+	var index ReleaseIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		return ReleaseIndex{}, fmt.Errorf("unable to parse the release index: %w", err)
+	}
+	index.BaseUrl = baseUrl
+
+	for key, release := range index.Versions {
+		if _, err := ParseSemVersion(key); err != nil {
+			delete(index.Versions, key)
+			continue
+		}
+		releaseUrl := baseUrl + key + "/"
+		if release.Shasums != "" {
+			release.ShasumsURL = releaseUrl + release.Shasums
+		}
+		if release.ShasumsSignature != "" {
+			release.SignatureURL = releaseUrl + release.ShasumsSignature
+		}
+		for i, build := range release.Builds {
+			release.Builds[i].URL = releaseUrl + build.Filename
+		}
+		index.Versions[key] = release
+	}
+	return index, nil
+}
+
+// LoadReleaseIndex returns the ReleaseIndex stored in fileName by SaveReleaseIndex
+func LoadReleaseIndex(fileSystem fs.FS, fileName string) (ReleaseIndex, error) {
+	var index ReleaseIndex
+	data, err := fs.ReadFile(fileSystem, fileName)
+	if err != nil {
+		return index, err
+	}
+	if err := json.Unmarshal(data, &index); err != nil {
+		return index, fmt.Errorf("unable to parse %s: %w", fileName, err)
+	}
+	return index, nil
+}
+
+// SaveReleaseIndex stores the ReleaseIndex in fileName
+func (index ReleaseIndex) SaveReleaseIndex(fileName string) error {
+	data, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fileName, data, 0644)
+}
+
+// VersionList returns the versions of every release, newest first
+func (index ReleaseIndex) VersionList() []string {
+	var versions []SemVersion
+	for key := range index.Versions {
+		versions = append(versions, *NewSemVersion(key))
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].IsGreaterThan(versions[j])
+	})
+
+	versionList := []string{}
+	for _, version := range versions {
+		versionList = append(versionList, version.ToString())
+	}
+	return versionList
+}
+
+// build returns the Build of the release archived as filename
+// the error lists the platforms the release was built for
+func (r Release) build(filename string) (Build, error) {
+	var platforms []string
+	for _, build := range r.Builds {
+		if build.Filename == filename {
+			return build, nil
+		}
+		platforms = append(platforms, build.OS+"_"+build.Arch)
+	}
+	return Build{}, fmt.Errorf("terraform %s has no %s build for this platform, it is available for %s",
+		r.Version, filename, strings.Join(platforms, ", "))
+}
+
+// verifyChecksum returns an error if the SHA256 of archive does not match its checksum listed in shasums
+// shasums is either a SHA256SUMS file listing the checksum of each of its filenames or a single checksum
+func verifyChecksum(archive []byte, shasums []byte, filenames ...string) error {
+	scanner := bufio.NewScanner(bytes.NewReader(shasums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		switch {
+		case len(fields) == 1:
+		case len(fields) == 2 && isAnyOf(strings.TrimPrefix(fields[1], "*"), filenames):
+		default:
+			continue
+		}
+		sum := sha256.Sum256(archive)
+		if actual := hex.EncodeToString(sum[:]); !strings.EqualFold(actual, fields[0]) {
+			return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", filenames[0], fields[0], actual)
+		}
+		return nil
+	}
+	return fmt.Errorf("no checksum listed for %s", filenames[0])
+}
+
+// isAnyOf returns true if s is one of values
+func isAnyOf(s string, values []string) bool {
+	for _, value := range values {
+		if s == value {
+			return true
+		}
+	}
+	return false
+}
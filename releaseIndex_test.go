@@ -0,0 +1,130 @@
+package versionedTerraform
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testReleaseIndex = `{
+  "name": "terraform",
+  "versions": {
+    "1.5.7": {
+      "name": "terraform",
+      "version": "1.5.7",
+      "shasums": "terraform_1.5.7_SHA256SUMS",
+      "shasums_signature": "terraform_1.5.7_SHA256SUMS.sig",
+      "builds": [
+        {"name": "terraform", "version": "1.5.7", "os": "linux", "arch": "amd64",
+         "filename": "terraform_1.5.7_linux_amd64.zip",
+         "url": "https://releases.hashicorp.com/terraform/1.5.7/terraform_1.5.7_linux_amd64.zip"},
+        {"name": "terraform", "version": "1.5.7", "os": "darwin", "arch": "arm64",
+         "filename": "terraform_1.5.7_darwin_arm64.zip",
+         "url": "https://releases.hashicorp.com/terraform/1.5.7/terraform_1.5.7_darwin_arm64.zip"}
+      ]
+    },
+    "1.6.0-rc1": {"version": "1.6.0-rc1", "shasums": "terraform_1.6.0-rc1_SHA256SUMS", "builds": []},
+    "0.13.7": {"version": "0.13.7", "builds": []},
+    "1.10.0": {"version": "1.10.0", "builds": []},
+    "not-a-version": {"version": "not-a-version", "builds": []}
+  }
+}`
+
+func TestParseReleaseIndex(t *testing.T) {
+	index, err := parseReleaseIndex([]byte(testReleaseIndex), "https://mirror.example.com/terraform/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"1.10.0", "1.6.0-rc1", "1.5.7", "0.13.7"}
+	if got := index.VersionList(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected versions %v, got %v", want, got)
+	}
+
+	release := index.Versions["1.5.7"]
+	if release.ShasumsURL != "https://mirror.example.com/terraform/1.5.7/terraform_1.5.7_SHA256SUMS" {
+		t.Errorf("Unexpected checksum URL %q", release.ShasumsURL)
+	}
+	if release.SignatureURL != "https://mirror.example.com/terraform/1.5.7/terraform_1.5.7_SHA256SUMS.sig" {
+		t.Errorf("Unexpected signature URL %q", release.SignatureURL)
+	}
+	if index.Versions["0.13.7"].ShasumsURL != "" {
+		t.Errorf("Expected no checksum URL for a release without checksums")
+	}
+
+	if _, err := parseReleaseIndex([]byte("<html>"), hashicorpUrl); err == nil {
+		t.Errorf("Expected an error for an invalid release index")
+	}
+}
+
+func TestRelease_build(t *testing.T) {
+	index, _ := parseReleaseIndex([]byte(testReleaseIndex), hashicorpUrl)
+	release := index.Versions["1.5.7"]
+
+	build, err := release.build("terraform_1.5.7_darwin_arm64.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if build.OS != "darwin" || build.Arch != "arm64" || build.URL != hashicorpUrl+"1.5.7/terraform_1.5.7_darwin_arm64.zip" {
+		t.Errorf("Unexpected build %+v", build)
+	}
+
+	_, err = release.build("terraform_1.5.7_solaris_amd64.zip")
+	want := "terraform 1.5.7 has no terraform_1.5.7_solaris_amd64.zip build for this platform, " +
+		"it is available for linux_amd64, darwin_arm64"
+	if err == nil || err.Error() != want {
+		t.Errorf("Expected %q, got %v", want, err)
+	}
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	archive := []byte("terraform archive")
+	sum := sha256.Sum256(archive)
+	shasums := []byte("0000  terraform_1.5.7_darwin_arm64.zip\n" +
+		hex.EncodeToString(sum[:]) + "  terraform_1.5.7_linux_amd64.zip\n")
+
+	cases := []struct {
+		name, filename string
+		archive        []byte
+		want           string
+	}{
+		{"matching checksum", "terraform_1.5.7_linux_amd64.zip", archive, ""},
+		{"checksum mismatch", "terraform_1.5.7_darwin_arm64.zip", archive,
+			"checksum mismatch for terraform_1.5.7_darwin_arm64.zip: expected 0000, got " + hex.EncodeToString(sum[:])},
+		{"checksum not listed", "terraform_1.5.7_windows_amd64.zip", archive,
+			"no checksum listed for terraform_1.5.7_windows_amd64.zip"},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run("Test: "+c.name, func(t *testing.T) {
+			t.Parallel()
+			err := verifyChecksum(c.archive, shasums, c.filename)
+			if c.want == "" && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+			if c.want != "" && (err == nil || err.Error() != c.want) {
+				t.Errorf("Expected %q, got %v", c.want, err)
+			}
+		})
+	}
+}
+
+func TestSaveReleaseIndex(t *testing.T) {
+	index, _ := parseReleaseIndex([]byte(testReleaseIndex), hashicorpUrl)
+	dir := t.TempDir()
+	if err := index.SaveReleaseIndex(filepath.Join(dir, ReleaseCacheFile)); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := LoadReleaseIndex(os.DirFS(dir), ReleaseCacheFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, index) {
+		t.Errorf("Expected the stored index\n     %+v\n got %+v", index, got)
+	}
+}
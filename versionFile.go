@@ -0,0 +1,194 @@
+package versionedTerraform
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"strings"
+)
+
+const (
+	terraformVersionFile = ".terraform-version"
+	toolVersionsFile     = ".tool-versions"
+	tfswitchTOMLFile     = ".tfswitch.toml"
+	tfswitchrcFile       = ".tfswitchrc"
+	versionFileComment   = "#"
+
+	toolVersionsPlugin = "terraform"
+	tfswitchVersionKey = "version"
+
+	// keywords understood in .terraform-version, as defined by tfenv
+	latestKeyword        = "latest"
+	latestKeywordPattern = "latest:"
+	minRequiredKeyword   = "min-required"
+	latestAllowedKeyword = "latest-allowed"
+)
+
+// versionFileFormat describes a file naming the terraform version, written by a version manager
+// value returns the version named by a single line without comments and whether the line names one
+// optional files may configure other tools and are skipped when they do not name a terraform version
+type versionFileFormat struct {
+	name     string
+	value    func(line string) (string, bool)
+	optional bool
+}
+
+// versionFiles lists the supported version files in order of precedence
+var versionFiles = []versionFileFormat{
+	{name: terraformVersionFile, value: plainVersionValue},
+	{name: toolVersionsFile, value: toolVersionsValue, optional: true},
+	{name: tfswitchTOMLFile, value: tfswitchTOMLValue, optional: true},
+	{name: tfswitchrcFile, value: plainVersionValue},
+}
+
+var preferVersionFile = true
+
+// SetPreferVersionFile sets whether a version file such as .terraform-version takes precedence over
+// required_version, when false it is only used by directories without any required_version
+// resolving with Options uses Options.PreferRequiredVersion instead
+func SetPreferVersionFile(prefer bool) {
+	preferVersionFile = prefer
+}
+
+// IsVersionFile returns true if the source was read from a version file rather than required_version
+func (s VersionSource) IsVersionFile() bool {
+	for _, format := range versionFiles {
+		if path.Base(s.File) == format.name {
+			return true
+		}
+	}
+	return false
+}
+
+// readVersionFile returns a VersionSource for the version named by the version file in dir
+// with the highest precedence, returns nil if there is no such file
+func readVersionFile(f fs.FS, dir string) (*VersionSource, error) {
+	for _, format := range versionFiles {
+		source, err := format.read(f, dir)
+		if err != nil || source != nil {
+			return source, err
+		}
+	}
+	return nil, nil
+}
+
+// read returns a VersionSource for the first line of the file in dir naming a version
+// returns nil if the file does not exist or is optional and does not name a version
+func (format versionFileFormat) read(f fs.FS, dir string) (*VersionSource, error) {
+	data, err := fs.ReadFile(f, path.Join(dir, format.name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i, line := range strings.Split(string(data), "\n") {
+		line, _, _ = cutString(line, versionFileComment)
+		if value, isSet := format.value(strings.TrimSpace(line)); isSet {
+			return &VersionSource{File: format.name, Line: i + 1, Constraint: value}, nil
+		}
+	}
+	if format.optional {
+		return nil, nil
+	}
+	return nil, fmt.Errorf("%s does not contain a version", format.name)
+}
+
+// plainVersionValue returns a line of a file containing only the version, as .terraform-version and .tfswitchrc
+func plainVersionValue(line string) (string, bool) {
+	return line, line != ""
+}
+
+// toolVersionsValue returns the version of a "terraform 1.5.7" line of an asdf .tool-versions file
+// asdf falls back to the versions after the first, only the first is used
+func toolVersionsValue(line string) (string, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 || fields[0] != toolVersionsPlugin {
+		return "", false
+	}
+	return fields[1], true
+}
+
+// tfswitchTOMLValue returns the version of a `version = "1.5.7"` line of a tfswitch .tfswitch.toml file
+func tfswitchTOMLValue(line string) (string, bool) {
+	key, value, isAssignment := cutString(line, "=")
+	if !isAssignment || strings.TrimSpace(key) != tfswitchVersionKey {
+		return "", false
+	}
+	value = strings.Trim(strings.TrimSpace(value), `"'`)
+	return value, value != ""
+}
+
+// versionFromVersionFile selects the version named by a version file source, every version file
+// accepts the keywords of .terraform-version
+// min-required and latest-allowed select the oldest and newest version satisfying the required_version sources
+func versionFromVersionFile(source VersionSource, sources []VersionSource, versionList []string, opts Options) (*Version, error) {
+	var version *Version
+	var err error
+	switch value := source.Constraint; {
+	case value == latestKeyword:
+		constraints, _ := ParseConstraints(defaultConstraint)
+		version, err = newVersion(constraints, versionList, opts.withStrategy(StrategyNewest))
+	case strings.HasPrefix(value, latestKeywordPattern):
+		return versionMatching(source, versionList, opts)
+	case value == minRequiredKeyword || value == latestAllowedKeyword:
+		if len(sources) == 0 {
+			return &Version{Sources: []VersionSource{source}},
+				fmt.Errorf("%s in %s line %d requires a required_version in the terraform files", value, source.File, source.Line)
+		}
+		strategy := StrategyNewest
+		if value == minRequiredKeyword {
+			strategy = StrategyOldest
+		}
+		version, err = versionFromSources(sources, versionList, opts.withStrategy(strategy))
+		version.Sources = append([]VersionSource{source}, version.Sources...)
+		return version, err
+	default:
+		exact, parseErr := ParseSemVersion(value)
+		if parseErr != nil {
+			return &Version{Sources: []VersionSource{source}},
+				fmt.Errorf("invalid version in %s line %d: %w", source.File, source.Line, parseErr)
+		}
+		version, err = newVersion(Constraints{{operator: versionEqual, version: exact}}, versionList,
+			opts.withStrategy(StrategyNewest))
+	}
+
+	version.Sources = []VersionSource{source}
+	if err != nil {
+		return version, fmt.Errorf("%s line %d: %w", source.File, source.Line, err)
+	}
+	return version, nil
+}
+
+// versionMatching selects the newest available version matching the regular expression of a latest:<regex> source
+// pre-releases may be selected if they match, unless only stable versions are required
+func versionMatching(source VersionSource, versionList []string, opts Options) (*Version, error) {
+	pattern := strings.TrimPrefix(source.Constraint, latestKeywordPattern)
+	expression, err := regexp.Compile(pattern)
+	if err != nil {
+		return &Version{Sources: []VersionSource{source}},
+			fmt.Errorf("invalid regular expression in %s line %d: %w", source.File, source.Line, err)
+	}
+
+	var matching []string
+	for _, release := range versionList {
+		if expression.MatchString(release) {
+			matching = append(matching, release)
+		}
+	}
+
+	version, err := newVersion(nil, matching, opts.withStrategy(StrategyNewest))
+	version.Sources = []VersionSource{source}
+	var unsatisfiable *UnsatisfiableError
+	if errors.As(err, &unsatisfiable) {
+		message := fmt.Sprintf("no available terraform version matches %q in %s line %d", pattern, source.File, source.Line)
+		if unsatisfiable.StableFiltered {
+			message += ", pre-release versions which match it were skipped because StableOnly is true"
+		}
+		return version, errors.New(message)
+	}
+	return version, err
+}
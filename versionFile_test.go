@@ -0,0 +1,158 @@
+package versionedTerraform
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestGetVersionFromFile_versionFile(t *testing.T) {
+	cases := []struct {
+		name, versionFile, requiredVersion string
+		stableOnly                         bool
+		want                               string
+	}{
+		{"exact version", "1.0.1\n", "", true, "1.0.1"},
+		{"exact version with prefix", "v0.13.0", "", true, "0.13.0"},
+		{"exact version with comments", "# pinned for CI\n\n0.12.30 # until the upgrade\n", "", true, "0.12.30"},
+		{"exact version over required_version", "1.0.1", ">= 1.1", true, "1.0.1"},
+		{"exact pre-release", "1.2.23-alpha", "", false, "1.2.23-alpha"},
+		{"latest", "latest", "", false, "1.1.11"},
+		{"latest over required_version", "latest", "~> 0.12.0", true, "1.1.11"},
+		{"latest regex", "latest:^0\\.13", "", true, "0.13.1"},
+		{"latest regex pre-release", "latest:^1\\.2", "", false, "1.2.23-alpha"},
+		{"min-required", "min-required", ">= 0.12, < 1.0", true, "0.12.30"},
+		{"latest-allowed", "latest-allowed", ">= 0.12, < 1.0", true, "0.14.0"},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run("Test: "+c.name, func(t *testing.T) {
+			fs := fstest.MapFS{".terraform-version": {Data: []byte(c.versionFile)}}
+			if c.requiredVersion != "" {
+				fs["versions.tf"] = &fstest.MapFile{Data: []byte("terraform {\n  required_version = \"" + c.requiredVersion + "\"\n}\n")}
+			}
+
+			got, err := GetVersionFromFile(fs, testVersionList(), c.stableOnly)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.VersionToString() != c.want {
+				t.Errorf("Expected %q, got %q", c.want, got.VersionToString())
+			}
+			if len(got.Sources) == 0 || !got.Sources[0].IsVersionFile() {
+				t.Errorf("Expected the first source to be the version file, got %+v", got.Sources)
+			}
+		})
+	}
+}
+
+func TestGetVersionFromFile_versionFileErrors(t *testing.T) {
+	cases := []struct {
+		name, versionFile, requiredVersion string
+		want                               string
+	}{
+		{"empty", "# no version\n", "",
+			".terraform-version does not contain a version"},
+		{"invalid version", "1.x", "",
+			`invalid version in .terraform-version line 1: invalid version "1.x": unexpected "x" at position 3`},
+		{"unavailable version", "\n9.9.9", "",
+			`.terraform-version line 2: no available terraform version satisfies "= 9.9.9" (closest below: 1.1.11, closest above: none)`},
+		{"invalid regex", "latest:(", "",
+			"invalid regular expression in .terraform-version line 1: error parsing regexp: missing closing ): `(`"},
+		{"unmatched regex", "latest:^1\\.2", "",
+			`no available terraform version matches "^1\\.2" in .terraform-version line 1, pre-release versions which match it were skipped because StableOnly is true`},
+		{"min-required without required_version", "min-required", "",
+			"min-required in .terraform-version line 1 requires a required_version in the terraform files"},
+		{"latest-allowed unsatisfiable", "latest-allowed", "> 5.0", `required_version in versions.tf line 2: ` +
+			`no available terraform version satisfies "> 5.0" (closest below: 1.1.11, closest above: none)`},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run("Test: "+c.name, func(t *testing.T) {
+			fs := fstest.MapFS{".terraform-version": {Data: []byte(c.versionFile)}}
+			if c.requiredVersion != "" {
+				fs["versions.tf"] = &fstest.MapFile{Data: []byte("terraform {\n  required_version = \"" + c.requiredVersion + "\"\n}\n")}
+			}
+
+			_, err := GetVersionFromFile(fs, testVersionList(), true)
+			if err == nil {
+				t.Fatalf("Expected an error for %q", c.versionFile)
+			}
+			if err.Error() != c.want {
+				t.Errorf("Expected %q, got %q", c.want, err.Error())
+			}
+		})
+	}
+}
+
+func TestGetVersionFromFile_preferRequiredVersion(t *testing.T) {
+	SetPreferVersionFile(false)
+	defer SetPreferVersionFile(true)
+
+	fs := fstest.MapFS{
+		".terraform-version": {Data: []byte("1.0.1")},
+		"versions.tf":        {Data: []byte(secondFile)},
+	}
+	got, err := GetVersionFromFile(fs, testVersionList(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.VersionToString() != "0.12.31" {
+		t.Errorf("Expected required_version to take precedence, got %q", got.VersionToString())
+	}
+
+	delete(fs, "versions.tf")
+	got, err = GetVersionFromFile(fs, testVersionList(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.VersionToString() != "1.0.1" {
+		t.Errorf("Expected the version file without required_version, got %q", got.VersionToString())
+	}
+}
+
+func TestGetVersionFromFile_otherVersionFiles(t *testing.T) {
+	cases := []struct {
+		name  string
+		files map[string]string
+		want  string
+		file  string
+		line  int
+	}{
+		{"tool-versions", map[string]string{".tool-versions": "nodejs 18.17.0\nterraform 1.0.1 0.14.0\n"},
+			"1.0.1", ".tool-versions", 2},
+		{"tool-versions without terraform", map[string]string{".tool-versions": "nodejs 18.17.0\n", "versions.tf": secondFile},
+			"0.12.31", "versions.tf", 3},
+		{"tfswitchrc", map[string]string{".tfswitchrc": "0.13.0\n"}, "0.13.0", ".tfswitchrc", 1},
+		{"tfswitch.toml", map[string]string{".tfswitch.toml": "bin = \"$HOME/bin/terraform\"\nversion = \"0.12.30\" # pinned\n"},
+			"0.12.30", ".tfswitch.toml", 2},
+		{"terraform-version before tool-versions", map[string]string{".terraform-version": "1.0.12", ".tool-versions": "terraform 1.0.1"},
+			"1.0.12", ".terraform-version", 1},
+		{"tool-versions before tfswitch", map[string]string{".tool-versions": "terraform 1.0.1", ".tfswitch.toml": "version = \"0.13.0\"", ".tfswitchrc": "0.13.1"},
+			"1.0.1", ".tool-versions", 1},
+		{"tfswitch.toml before tfswitchrc", map[string]string{".tfswitch.toml": "version = \"0.13.0\"", ".tfswitchrc": "0.13.1"},
+			"0.13.0", ".tfswitch.toml", 1},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run("Test: "+c.name, func(t *testing.T) {
+			fs := fstest.MapFS{}
+			for name, content := range c.files {
+				fs[name] = &fstest.MapFile{Data: []byte(content)}
+			}
+
+			got, err := GetVersionFromFile(fs, testVersionList(), true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.VersionToString() != c.want {
+				t.Errorf("Expected %q, got %q", c.want, got.VersionToString())
+			}
+			if len(got.Sources) == 0 || got.Sources[0].File != c.file || got.Sources[0].Line != c.line {
+				t.Errorf("Expected the version from %s line %d, got %+v", c.file, c.line, got.Sources)
+			}
+		})
+	}
+}
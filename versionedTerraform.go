@@ -2,22 +2,47 @@ package versionedTerraform
 
 import (
 	"archive/zip"
-	"bufio"
 	"bytes"
 	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
-	"net/http"
 	"os"
-	"regexp"
+	"path"
 	"strings"
 )
 
 type Version struct {
 	Version           SemVersion
+	Constraints       Constraints
+	Sources           []VersionSource
+	Dir               string
+	Warnings          []string
+	required          *Version
 	availableVersions []SemVersion
 	installedVersions []SemVersion
+	stableOnly        bool
+	strategy          Strategy
+}
+
+// Explanation describes how a Version was selected from the available versions
+type Explanation struct {
+	Constraints    Constraints
+	Sources        []VersionSource
+	Dir            string
+	Available      []SemVersion
+	StableOnly     bool
+	Strategy       Strategy
+	StableFiltered []SemVersion
+	Clauses        []ClauseExplanation
+	Selected       SemVersion
+}
+
+// ClauseExplanation lists the candidate versions a single clause eliminated
+// and the sources which contributed the clause
+type ClauseExplanation struct {
+	Constraint Constraint
+	Sources    []VersionSource
+	Eliminated []SemVersion
 }
 
 const (
@@ -26,64 +51,9 @@ const (
 	versionedTerraformFolder = "/.versionedTerraform"
 )
 
-// getLatestMajorRelease() returns the latest major release from Version
-func (v *Version) getLatestMajorRelease() {
-	for _, release := range v.availableVersions {
-		if release.majorVersion == v.Version.majorVersion &&
-			release.minorVersion == v.Version.minorVersion &&
-			release.patchVersion >= v.Version.patchVersion &&
-			(v.Version.isStable || !needsStable) {
-			v.Version = release
-		}
-	}
-}
-
-// getGreatestRelease() returns less than release
-func (v *Version) getOneLessRelease() {
-	var vSlice []Version
-
-	for _, release := range v.availableVersions {
-		_v := Version{
-			Version:           release,
-			availableVersions: v.availableVersions,
-			installedVersions: v.installedVersions,
-		}
-
-		if isVersionGreater(*v, _v) {
-			vSlice = append(vSlice, _v)
-		}
-	}
-
-	for i, ver := range vSlice {
-		if isVersionGreater(ver, *v) || i == 0 {
-			*v = ver
-		}
-	}
-}
-
-// getLatestRelease returns the latest release from Version
-func (v *Version) getLatestRelease() {
-	for _, release := range v.availableVersions {
-		if release.majorVersion > v.Version.majorVersion &&
-			(release.isStable || !needsStable) {
-			v.Version = release
-		}
-		if release.majorVersion >= v.Version.majorVersion &&
-			release.minorVersion > v.Version.minorVersion &&
-			(release.isStable || !needsStable) {
-			v.Version = release
-		}
-		if release.majorVersion >= v.Version.majorVersion &&
-			release.minorVersion >= v.Version.minorVersion &&
-			release.patchVersion >= v.Version.patchVersion &&
-			(release.isStable || !needsStable) {
-			v.Version = release
-		}
-	}
-}
-
 // InstallTerraformVersion installs the defined terraform Version in the application
-// configuration directory
+// configuration directory, the archive is located with the release index, or the URL templates
+// set by SetUrlTemplates, and its checksum verified
 func (v *Version) InstallTerraformVersion() error {
 	homeDir, _ := os.UserHomeDir()
 	suffix := fileSuffix
@@ -91,21 +61,25 @@ func (v *Version) InstallTerraformVersion() error {
 	if v.Version.IsLessThan(*minV) {
 		suffix = alternateSuffix
 	}
-	url := hashicorpUrl +
-		v.Version.ToString() +
-		"/" + terraformPrefix +
-		v.Version.ToString() +
-		suffix
 
-	resp, err := http.Get(url)
+	release, build, err := findBuild(homeDir+versionedTerraformFolder, v.Version.ToString(), suffix)
 	if err != nil {
-		return fmt.Errorf("failed to download Terraform: %v", err)
+		return fmt.Errorf("failed to download Terraform: %w", err)
 	}
-	defer resp.Body.Close()
 
-	body, err := ioutil.ReadAll(resp.Body)
+	body, err := fetch(build.URL)
 	if err != nil {
-		return fmt.Errorf("failed to read response body: %v", err)
+		return fmt.Errorf("failed to download Terraform: %w", err)
+	}
+	if release.ShasumsURL == "" {
+		return fmt.Errorf("failed to verify Terraform: no checksums are listed for %s", release.Version)
+	}
+	shasums, err := fetch(release.ShasumsURL)
+	if err != nil {
+		return fmt.Errorf("failed to download Terraform checksums: %w", err)
+	}
+	if err := verifyChecksum(body, shasums, build.Filename, path.Base(build.URL)); err != nil {
+		return fmt.Errorf("failed to verify Terraform: %v", err)
 	}
 
 	zipReader, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
@@ -143,99 +117,162 @@ func (v *Version) InstallTerraformVersion() error {
 }
 
 // NewVersion creates a new Version using sem versioning for determining the
-// latest release
-func NewVersion(_version string, _vList []string) *Version {
+// latest release, every clause of a comma separated constraint must be
+// satisfied by the selected release
+func NewVersion(_version string, _vList []string) (*Version, error) {
+	return packageOptions(needsStable).NewVersion(_version, _vList)
+}
+
+// NewVersion is like the package level NewVersion resolving with o
+func (o Options) NewVersion(_version string, _vList []string) (*Version, error) {
+	constraints, err := ParseConstraints(_version)
+	if err != nil {
+		return &Version{}, err
+	}
+	return newVersion(constraints, _vList, o)
+}
+
+// newVersion creates a new Version selecting the release satisfying constraints as decided by opts
+func newVersion(constraints Constraints, _vList []string, opts Options) (*Version, error) {
 	v := new(Version)
-	v.Version = *NewSemVersion(_version)
+	v.stableOnly = opts.StableOnly
+	v.strategy = opts.Strategy
+	v.installedVersions = opts.InstalledVersions
+	v.Constraints = constraints
 
 	for _, release := range _vList {
-		v.availableVersions = append(v.availableVersions, *NewSemVersion(release))
+		semVersion, err := ParseSemVersion(release)
+		if err != nil {
+			return &Version{}, fmt.Errorf("invalid available version: %w", err)
+		}
+		v.availableVersions = append(v.availableVersions, semVersion)
 	}
 
-	hasMultiVersions, _ := regexp.MatchString("\\d+,", v.Version.ToString())
-
-	if hasMultiVersions {
-		releases := strings.Split(v.Version.ToString(), ",")
-		for iteration, _release := range releases {
-			_v := new(Version)
-			_v.availableVersions = v.availableVersions
-			switch {
-			case strings.Contains(_release, latestRelease):
-				release := strings.Split(_release, latestRelease)[1]
-				_v.Version = *NewSemVersion(release)
-				_v.getLatestRelease()
-			case strings.Contains(_release, latestPatch):
-				release := strings.Split(_release, latestPatch)[1]
-				_v.Version = *NewSemVersion(release)
-				_v.getLatestMajorRelease()
-			case strings.Contains(_release, versionLessOrEqual):
-				release := strings.Split(_release, versionLessOrEqual)[1]
-				_v.Version = *NewSemVersion(release)
-			case strings.Contains(_release, versionLessThan):
-				release := strings.Split(_release, versionLessThan)[1]
-				_v.Version = *NewSemVersion(release)
-				_v.getOneLessRelease()
-			}
+	var err error
+	v.Version, err = v.selectVersion()
+	if err != nil {
+		var unsatisfiable *UnsatisfiableError
+		if errors.As(err, &unsatisfiable) && v.stableOnly {
+			_, allErr := v.Constraints.Select(v.availableVersions)
+			unsatisfiable.StableFiltered = allErr == nil
+		}
+		return v, err
+	}
 
-			if isVersionGreater(*_v, *v) || iteration == 0 {
-				v = _v
-			}
+	return v, nil
+}
+
+// selectVersion returns the candidate version satisfying Version.Constraints chosen by Version.strategy
+func (v *Version) selectVersion() (SemVersion, error) {
+	switch v.strategy {
+	case StrategyOldest:
+		return v.Constraints.SelectOldest(v.candidateVersions())
+	case StrategyPreferInstalled:
+		installed, err := v.Constraints.Select(v.stableVersions(v.installedVersions))
+		if err == nil {
+			return installed, nil
+		}
+	}
+	return v.Constraints.Select(v.candidateVersions())
+}
+
+// candidateVersions returns the available versions which may be selected,
+// pre-releases are left out when only stable versions are required
+func (v *Version) candidateVersions() []SemVersion {
+	return v.stableVersions(v.availableVersions)
+}
+
+// stableVersions returns versions without pre-releases when only stable versions are required
+func (v *Version) stableVersions(versions []SemVersion) []SemVersion {
+	var stable []SemVersion
+	for _, release := range versions {
+		if !release.isStable && v.stableOnly {
+			continue
 		}
+		stable = append(stable, release)
+	}
+	return stable
+}
+
+// Matrix returns the newest version of every major.minor release satisfying
+// Version.Constraints, pre-releases are left out when only stable versions are required
+// when a version file was selected over required_version the matrix is what required_version allows
+func (v *Version) Matrix() []SemVersion {
+	if v.required != nil {
+		return v.required.Matrix()
+	}
+	return v.Constraints.Matrix(v.candidateVersions())
+}
 
-		return v
+// Explain returns an Explanation of which available versions were eliminated by
+// StableOnly and by each clause of the constraint, every clause is checked
+// against all candidates so a version may be eliminated by more than one clause
+func (v *Version) Explain() Explanation {
+	explanation := Explanation{
+		Constraints: v.Constraints,
+		Sources:     v.Sources,
+		Dir:         v.Dir,
+		Available:   v.availableVersions,
+		StableOnly:  v.stableOnly,
+		Strategy:    v.strategy,
+		Selected:    v.Version,
 	}
 
-	switch {
-	case strings.Contains(v.Version.ToString(), latestRelease):
-		release := strings.Split(v.Version.ToString(), latestRelease)[1]
-		v.Version = *NewSemVersion(release)
-		v.getLatestRelease()
-	case strings.Contains(v.Version.ToString(), latestPatch):
-		release := strings.Split(v.Version.ToString(), latestPatch)[1]
-		v.Version = *NewSemVersion(release)
-		v.getLatestMajorRelease()
-	case strings.Contains(v.Version.ToString(), versionLessOrEqual):
-		release := strings.Split(v.Version.ToString(), versionLessOrEqual)[1]
-		v.Version = *NewSemVersion(release)
-	case strings.Contains(v.Version.ToString(), versionLessThan):
-		release := strings.Split(v.Version.ToString(), versionLessThan)[1]
-		v.Version = *NewSemVersion(release)
-		v.getOneLessRelease()
+	for _, release := range v.availableVersions {
+		if !release.isStable && v.stableOnly {
+			explanation.StableFiltered = append(explanation.StableFiltered, release)
+		}
 	}
 
-	return v
+	candidates := v.candidateVersions()
+	for _, constraint := range v.Constraints {
+		clause := ClauseExplanation{Constraint: constraint}
+		for _, source := range v.Sources {
+			sourceConstraints, _ := ParseConstraints(source.Constraint)
+			for _, sourceConstraint := range sourceConstraints {
+				if sourceConstraint.String() == constraint.String() {
+					clause.Sources = append(clause.Sources, source)
+					break
+				}
+			}
+		}
+		for _, release := range candidates {
+			if !constraint.Check(release) {
+				clause.Eliminated = append(clause.Eliminated, release)
+			}
+		}
+		explanation.Clauses = append(explanation.Clauses, clause)
+	}
+	return explanation
 }
 
-// GetVersionList returns a list of available versions from hashicorp's release page
+// GetVersionList returns a list of available versions from hashicorp's release index, or the
+// mirror set by SetMirrorUrl, newest first
 func GetVersionList() ([]string, error) {
-	var versionList []string
-	resp, err := http.Get(hashicorpUrl)
+	index, err := GetReleaseIndex()
 	if err != nil {
-		return versionList, err
+		return []string{}, err
 	}
+	return index.VersionList(), nil
+}
 
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return versionList, errors.New("invalid response code")
+// findRelease returns the Release of version from the release index stored in configDir
+// the index is fetched again if it is not stored, does not list version or was fetched from another mirror
+func findRelease(configDir string, version string) (Release, error) {
+	index, err := LoadReleaseIndex(os.DirFS(configDir), ReleaseCacheFile)
+	if release, isListed := index.Versions[version]; err == nil && isListed && index.BaseUrl == releaseBaseUrl {
+		return release, nil
 	}
 
-	body, err := io.ReadAll(resp.Body)
-
-	//todo maybe change this like GetVersionFromFile and consolidate
-	bodyText := string(body)
-	scanner := bufio.NewScanner(strings.NewReader(bodyText))
-	for scanner.Scan() {
-		_line := scanner.Text()
-		if strings.Contains(_line, "a href") {
-			_lineSplice := strings.Split(_line, terraformPrefix)
-			if len(_lineSplice) == 2 {
-				_line = _lineSplice[1]
-				_line = strings.Split(_line, "</a")[0]
-				versionList = append(versionList, _line)
-			}
-		}
+	index, err = GetReleaseIndex()
+	if err != nil {
+		return Release{}, err
 	}
-	return versionList, nil
+	release, isListed := index.Versions[version]
+	if !isListed {
+		return Release{}, fmt.Errorf("terraform %s is not listed in the release index", version)
+	}
+	return release, nil
 }
 
 // removeSpacesVersion removes spaces from Version string for parsing
@@ -253,29 +290,3 @@ func removeSpacesVersion(v string) string {
 func (v *Version) VersionToString() string {
 	return v.Version.ToString()
 }
-
-// isVersionGreater returns true if v1 is greater than v2
-func isVersionGreater(v1 Version, v2 Version) bool {
-	if v1.Version.majorVersion != v2.Version.majorVersion {
-		if v1.Version.majorVersion > v2.Version.majorVersion {
-			return true
-		}
-		return false
-	}
-
-	if v1.Version.minorVersion != v2.Version.minorVersion {
-		if v1.Version.minorVersion > v2.Version.minorVersion {
-			return true
-		}
-		return false
-	}
-
-	if v1.Version.patchVersion != v2.Version.patchVersion {
-		if v1.Version.patchVersion > v2.Version.patchVersion {
-			return true
-		}
-		return false
-	}
-
-	return false
-}
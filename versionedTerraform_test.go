@@ -1,7 +1,21 @@
 package versionedTerraform
 
 import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
 	"testing"
+	"testing/fstest"
 )
 
 func testVersionList() []string {
@@ -42,18 +56,30 @@ func TestGetVersion(t *testing.T) {
 		{testVersionList(), "~>0.12.4", "0.12.31"},
 		{testVersionList(), ">= 0.11.15", "1.1.11"},
 		{testVersionList(), ">= 0.12.0", "1.1.11"},
-		{testVersionList(), "~> 0.12", "0.12.31"},
+		{testVersionList(), "~> 0.12", "0.14.0"},
+		{testVersionList(), "~> 0.12.0", "0.12.31"},
+		{testVersionList(), "~> 1.0", "1.1.11"},
+		{testVersionList(), "~> 1.0.0", "1.0.12"},
 		{testVersionList(), "< 0.12", "0.11.15"},
 		{testVersionList(), "<= 0.12.31", "0.12.31"},
 		{testVersionList(), "~> 0.12.0, < 0.13", "0.12.31"},
-		{testVersionList(), "~> 0.12.0, < 0.14", "0.13.1"},
-		{testVersionList(), "~> 0.12.0, <= 0.14.0", "0.14.0"},
+		{testVersionList(), "~> 0.12.0, < 0.14", "0.12.31"},
+		{testVersionList(), "~> 0.12.0, <= 0.14.0", "0.12.31"},
+		{testVersionList(), ">= 0.11.10, < 0.12", "0.11.15"},
+		{testVersionList(), ">= 1.0, < 1.1.5, != 1.1.4", "1.1.3"},
+		{testVersionList(), "> 0.13.0, != 0.13.1, < 1.0", "0.14.0"},
+		{testVersionList(), "= 0.13.0", "0.13.0"},
+		{testVersionList(), "v0.13.0", "0.13.0"},
+		{testVersionList(), ">= v0.12.30, <= v0.12.31", "0.12.31"},
 	}
 
 	for _, c := range cases {
 		t.Run("test Version check with various conditions: "+c.version, func(t *testing.T) {
 			//t.Parallel()
-			got := NewVersion(c.version, c.available)
+			got, err := NewVersion(c.version, c.available)
+			if err != nil {
+				t.Fatal(err)
+			}
 			if got.Version.version != c.expected {
 				t.Errorf("got %q, want %q", got.Version.version, c.expected)
 			}
@@ -61,6 +87,189 @@ func TestGetVersion(t *testing.T) {
 	}
 }
 
+func TestGetVersion_preRelease(t *testing.T) {
+	defer func(value bool) { needsStable = value }(needsStable)
+	needsStable = false
+
+	available := []string{"1.5.7", "1.6.0-alpha20230719", "1.6.0-rc1", "1.6.0-beta1", "1.7.0-alpha1"}
+	cases := []struct {
+		version, expected string
+	}{
+		{">= 1.5.0", "1.5.7"},
+		{">= 1.6.0-alpha20230719", "1.6.0-rc1"},
+		{"1.6.0-beta1", "1.6.0-beta1"},
+	}
+
+	for _, c := range cases {
+		t.Run("test Version check with pre-releases: "+c.version, func(t *testing.T) {
+			got, err := NewVersion(c.version, available)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Version.version != c.expected {
+				t.Errorf("got %q, want %q", got.Version.version, c.expected)
+			}
+		})
+	}
+}
+
+func TestGetVersion_invalid(t *testing.T) {
+	cases := []struct {
+		available         []string
+		version, expected string
+	}{
+		{testVersionList(), "", `invalid version constraint "": missing version number at position 1`},
+		{testVersionList(), ">= 1.x", `invalid version constraint ">= 1.x": unexpected "x" at position 6`},
+		{testVersionList(), ">= 1.0,", `invalid version constraint ">= 1.0,": missing version number at position 8`},
+		{[]string{"1.0.0", ""}, ">= 1.0", `invalid available version: invalid version "": missing version number at position 1`},
+	}
+
+	for _, c := range cases {
+		t.Run("test Version check with invalid input: "+c.version, func(t *testing.T) {
+			_, err := NewVersion(c.version, c.available)
+			if err == nil {
+				t.Fatalf("expected an error for %q", c.version)
+			}
+			if err.Error() != c.expected {
+				t.Errorf("got %q, want %q", err.Error(), c.expected)
+			}
+		})
+	}
+}
+
+func TestGetVersion_unsatisfiable(t *testing.T) {
+	cases := []struct {
+		name, version, below, above string
+		available                   []string
+		stableOnly, stableFiltered  bool
+	}{
+		{"above all releases", "<= 9.9.9, > 5.0", "1.2.23-alpha", "none", testVersionList(), false, false},
+		{"below all releases", "< 0.11", "none", "0.11.10", testVersionList(), true, false},
+		{"between releases", ">= 0.12.0, < 0.12.30", "0.11.15", "0.12.30", testVersionList(), true, false},
+		{"missing exact release", "1.1.0", "1.0.12", "1.1.1", testVersionList(), true, false},
+		{"only pre-release satisfies", ">= 1.2.23-alpha", "1.1.11", "none", testVersionList(), true, true},
+		{"pre-release not named", ">= 1.2", "1.1.11", "1.2.23-alpha", testVersionList(), false, false},
+		{"no available versions", ">= 0.0.0", "none", "none", []string{}, true, false},
+	}
+
+	for _, c := range cases {
+		t.Run("test unsatisfiable constraint: "+c.name, func(t *testing.T) {
+			defer func(value bool) { needsStable = value }(needsStable)
+			needsStable = c.stableOnly
+
+			_, err := NewVersion(c.version, c.available)
+			var unsatisfiable *UnsatisfiableError
+			if !errors.As(err, &unsatisfiable) {
+				t.Fatalf("expected an UnsatisfiableError got %v", err)
+			}
+
+			below, above := "none", "none"
+			if unsatisfiable.Below != nil {
+				below = unsatisfiable.Below.ToString()
+			}
+			if unsatisfiable.Above != nil {
+				above = unsatisfiable.Above.ToString()
+			}
+			if below != c.below || above != c.above {
+				t.Errorf("got closest %s and %s, want %s and %s", below, above, c.below, c.above)
+			}
+			if unsatisfiable.StableFiltered != c.stableFiltered {
+				t.Errorf("got StableFiltered %t, want %t", unsatisfiable.StableFiltered, c.stableFiltered)
+			}
+		})
+	}
+}
+
+func TestVersion_Explain(t *testing.T) {
+	defer func(value bool) { needsStable = value }(needsStable)
+	needsStable = true
+
+	v, err := NewVersion(">= 0.12.31, < 0.14", testVersionList())
+	if err != nil {
+		t.Fatal(err)
+	}
+	explanation := v.Explain()
+
+	if explanation.Selected.ToString() != "0.13.1" {
+		t.Errorf("got selected %q, want %q", explanation.Selected.ToString(), "0.13.1")
+	}
+	if len(explanation.StableFiltered) != 1 || explanation.StableFiltered[0].ToString() != "1.2.23-alpha" {
+		t.Errorf("got StableFiltered %+v, want [1.2.23-alpha]", explanation.StableFiltered)
+	}
+	if len(explanation.Clauses) != 2 {
+		t.Fatalf("got %d clauses, want 2", len(explanation.Clauses))
+	}
+
+	want := map[string]int{">= 0.12.31": 3, "< 0.14": 14}
+	for _, clause := range explanation.Clauses {
+		if len(clause.Eliminated) != want[clause.Constraint.String()] {
+			t.Errorf("clause %q eliminated %d versions, want %d",
+				clause.Constraint.String(), len(clause.Eliminated), want[clause.Constraint.String()])
+		}
+	}
+}
+
+func TestVersion_Matrix(t *testing.T) {
+	defer func(value bool) { needsStable = value }(needsStable)
+	needsStable = true
+
+	v, err := NewVersion(">= 1.0", testVersionList())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := v.Matrix()
+	want := []string{"1.0.12", "1.1.11"}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v, want %v", got, want)
+	}
+	for i, version := range got {
+		if version.ToString() != want[i] {
+			t.Errorf("got %q at %d, want %q", version.ToString(), i, want[i])
+		}
+	}
+}
+
+func TestVersion_Matrix_versionFile(t *testing.T) {
+	defer func(value bool) { needsStable = value }(needsStable)
+	needsStable = true
+
+	cases := []struct {
+		name  string
+		files fstest.MapFS
+		want  []string
+	}{
+		{"pinned with required_version", fstest.MapFS{
+			".terraform-version": {Data: []byte("1.1.11\n")},
+			"versions.tf":        {Data: []byte("terraform {\n  required_version = \">= 1.0\"\n}\n")},
+		}, []string{"1.0.12", "1.1.11"}},
+		{"pinned without required_version", fstest.MapFS{
+			".terraform-version": {Data: []byte("1.1.11\n")},
+		}, []string{"1.1.11"}},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run("Test: "+c.name, func(t *testing.T) {
+			v, err := GetVersionFromFile(c.files, testVersionList(), true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v.VersionToString() != "1.1.11" {
+				t.Errorf("Expected the pinned 1.1.11, got %s", v.VersionToString())
+			}
+
+			var got []string
+			for _, version := range v.Matrix() {
+				got = append(got, version.ToString())
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
 func TestRemoveSpacesVersion(t *testing.T) {
 	cases := []struct {
 		tesValue, want string
@@ -83,71 +292,216 @@ func TestRemoveSpacesVersion(t *testing.T) {
 	}
 }
 
-func TestIsVersionGreater(t *testing.T) {
+// testMirror writes a mirror in the releases.hashicorp.com layout to a temporary directory with a
+// build of version for the current platform and returns the directory
+func testMirror(t *testing.T, version string, binary string) string {
+	dir := t.TempDir()
+	filename := terraformPrefix + version + fileSuffix
+
+	archive := new(bytes.Buffer)
+	zipWriter := zip.NewWriter(archive)
+	binaryWriter, _ := zipWriter.Create("terraform")
+	binaryWriter.Write([]byte(binary))
+	zipWriter.Close()
+	sum := sha256.Sum256(archive.Bytes())
+
+	index := fmt.Sprintf(`{"name": "terraform", "versions": {"%[1]s": {"version": "%[1]s",
+		"shasums": "terraform_%[1]s_SHA256SUMS", "builds": [{"os": "%[2]s", "arch": "%[3]s",
+		"filename": "%[4]s", "url": "https://releases.hashicorp.com/terraform/%[1]s/%[4]s"}]}}}`,
+		version, runtime.GOOS, runtime.GOARCH, filename)
+
+	os.MkdirAll(filepath.Join(dir, version), 0755)
+	os.WriteFile(filepath.Join(dir, "index.json"), []byte(index), 0644)
+	os.WriteFile(filepath.Join(dir, version, filename), archive.Bytes(), 0644)
+	os.WriteFile(filepath.Join(dir, version, "terraform_"+version+"_SHA256SUMS"),
+		[]byte(hex.EncodeToString(sum[:])+"  "+filename+"\n"), 0644)
+	return dir
+}
+
+func TestGetVersionList(t *testing.T) {
+	defer SetMirrorUrl(hashicorpUrl)
+	if err := SetMirrorUrl("file://" + filepath.ToSlash(testMirror(t, "1.5.7", "#!/bin/sh\n"))); err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := GetVersionList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(response) != 1 || response[0] != "1.5.7" {
+		t.Errorf("Expected [1.5.7], got %v", response)
+	}
+}
+
+func TestInstallTerraformVersion(t *testing.T) {
+	defer SetMirrorUrl(hashicorpUrl)
+	mirrorDir := testMirror(t, "1.5.7", "#!/bin/sh\necho terraform\n")
+	server := httptest.NewServer(http.FileServer(http.Dir(mirrorDir)))
+	defer server.Close()
+
 	cases := []struct {
-		testName                   string
-		testValueOne, testValueTwo Version
-		want                       bool
+		name, mirrorUrl string
 	}{
-		{"equal versions",
-			*NewVersion("0.12.10", testVersionList()),
-			*NewVersion("0.12.10", testVersionList()),
-			false,
-		},
-		{"major greater versions",
-			*NewVersion("1.12.10", testVersionList()),
-			*NewVersion("0.12.10", testVersionList()),
-			true,
-		},
-		{"major less versions",
-			*NewVersion("0.12.10", testVersionList()),
-			*NewVersion("1.12.10", testVersionList()),
-			false,
-		},
-		{"minor greater versions",
-			*NewVersion("0.13.10", testVersionList()),
-			*NewVersion("0.12.10", testVersionList()),
-			true,
-		},
-		{"minor less  versions",
-			*NewVersion("0.12.10", testVersionList()),
-			*NewVersion("0.13.10", testVersionList()),
-			false,
-		},
-		{"patch greater versions",
-			*NewVersion("0.12.11", testVersionList()),
-			*NewVersion("0.12.10", testVersionList()),
-			true,
-		},
-		{"patch less versions",
-			*NewVersion("0.12.10", testVersionList()),
-			*NewVersion("0.12.11", testVersionList()),
-			false,
-		},
+		{"http mirror", server.URL},
+		{"file mirror", "file://" + filepath.ToSlash(mirrorDir) + "/"},
 	}
 
 	for _, c := range cases {
-		t.Run("test isVersionGreater: "+c.testName, func(t *testing.T) {
-			got := isVersionGreater(c.testValueOne, c.testValueTwo)
-			if got != c.want {
-				t.Errorf("Got %t, want %t", got, c.want)
+		t.Run("Test: "+c.name, func(t *testing.T) {
+			homeDir := t.TempDir()
+			t.Setenv("HOME", homeDir)
+			os.MkdirAll(homeDir+versionedTerraformFolder, 0755)
+			if err := SetMirrorUrl(c.mirrorUrl); err != nil {
+				t.Fatal(err)
+			}
+
+			version := Version{Version: *NewSemVersion("1.5.7")}
+			if err := version.InstallTerraformVersion(); err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.ReadFile(homeDir + versionedTerraformFolder + "/" + terraformPrefix + "1.5.7")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != "#!/bin/sh\necho terraform\n" {
+				t.Errorf("Unexpected terraform binary %q", got)
 			}
 		})
 	}
+
+	t.Run("Test: checksum mismatch", func(t *testing.T) {
+		homeDir := t.TempDir()
+		t.Setenv("HOME", homeDir)
+		os.MkdirAll(homeDir+versionedTerraformFolder, 0755)
+		os.WriteFile(filepath.Join(mirrorDir, "1.5.7", "terraform_1.5.7_SHA256SUMS"),
+			[]byte("0000  "+terraformPrefix+"1.5.7"+fileSuffix+"\n"), 0644)
+		SetMirrorUrl(server.URL)
+
+		version := Version{Version: *NewSemVersion("1.5.7")}
+		err := version.InstallTerraformVersion()
+		if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+			t.Errorf("Expected a checksum mismatch, got %v", err)
+		}
+		if _, err := os.Stat(homeDir + versionedTerraformFolder + "/" + terraformPrefix + "1.5.7"); err == nil {
+			t.Errorf("Expected terraform not to be installed")
+		}
+	})
 }
 
-func TestGetVersionList(t *testing.T) {
-	//todo write test for this
-	//response, _ := GetVersionList()
-	//for _, Version := range response {
-	//	t.Errorf("%v", Version)
-	//}
-	//t.Errorf("%v", response)
+func TestSetMirrorUrl(t *testing.T) {
+	defer SetMirrorUrl(hashicorpUrl)
+	cases := []struct {
+		mirrorUrl, want string
+		isValid         bool
+	}{
+		{"https://artifactory.example.com/hashicorp/terraform", "https://artifactory.example.com/hashicorp/terraform/", true},
+		{"http://nexus.internal/terraform/", "http://nexus.internal/terraform/", true},
+		{"file:///srv/mirror/terraform", "file:///srv/mirror/terraform/", true},
+		{"ftp://mirror.example.com/terraform/", "", false},
+		{"releases.example.com/terraform/", "", false},
+	}
+
+	for _, c := range cases {
+		t.Run("test mirror url: "+c.mirrorUrl, func(t *testing.T) {
+			releaseBaseUrl = hashicorpUrl
+			err := SetMirrorUrl(c.mirrorUrl)
+			if (err == nil) != c.isValid {
+				t.Fatalf("SetMirrorUrl returned error %v, expected valid %t", err, c.isValid)
+			}
+			if c.isValid && releaseBaseUrl != c.want {
+				t.Errorf("Expected %q, got %q", c.want, releaseBaseUrl)
+			}
+		})
+	}
 }
 
-func TestInstallTerraformVersion(t *testing.T) {
-	//todo write test for this
-	//Version := NewVersion("0.12.31", testVersionList())
-	//response := Version.InstallTerraformVersion()
-	//t.Errorf("%v", response)
+func TestInstallTerraformVersion_urlTemplates(t *testing.T) {
+	defer SetMirrorUrl(hashicorpUrl)
+	defer SetUrlTemplates("", "", "")
+
+	// a mirror which renames archives and publishes a single checksum per archive without a release index
+	mirrorDir := testMirror(t, "1.5.7", "#!/bin/sh\necho flattened\n")
+	archive, _ := os.ReadFile(filepath.Join(mirrorDir, "1.5.7", terraformPrefix+"1.5.7"+fileSuffix))
+	sum := sha256.Sum256(archive)
+	toolsDir := filepath.Join(mirrorDir, "tools", "terraform", "1.5.7")
+	os.MkdirAll(toolsDir, 0755)
+	archiveName := expandUrlTemplate("terraform-{os}-{arch}.zip", "1.5.7", fileSuffix)
+	os.WriteFile(filepath.Join(toolsDir, archiveName), archive, 0644)
+	os.WriteFile(filepath.Join(toolsDir, archiveName+".sha256"), []byte(hex.EncodeToString(sum[:])+"\n"), 0644)
+	os.Remove(filepath.Join(mirrorDir, "index.json"))
+
+	server := httptest.NewServer(http.FileServer(http.Dir(mirrorDir)))
+	defer server.Close()
+	SetMirrorUrl(server.URL)
+	err := SetUrlTemplates("",
+		server.URL+"/tools/terraform/{version}/terraform-{os}-{arch}.zip",
+		server.URL+"/tools/terraform/{version}/terraform-{os}-{arch}.zip.sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	homeDir := t.TempDir()
+	t.Setenv("HOME", homeDir)
+	os.MkdirAll(homeDir+versionedTerraformFolder, 0755)
+
+	version := Version{Version: *NewSemVersion("1.5.7")}
+	if err := version.InstallTerraformVersion(); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := os.ReadFile(homeDir + versionedTerraformFolder + "/" + terraformPrefix + "1.5.7")
+	if string(got) != "#!/bin/sh\necho flattened\n" {
+		t.Errorf("Unexpected terraform binary %q", got)
+	}
+}
+
+func TestGetVersionList_indexUrl(t *testing.T) {
+	defer SetUrlTemplates("", "", "")
+	mirrorDir := testMirror(t, "1.4.6", "")
+	os.Rename(filepath.Join(mirrorDir, "index.json"), filepath.Join(mirrorDir, "terraform-releases.json"))
+
+	if err := SetUrlTemplates("file://"+filepath.ToSlash(mirrorDir)+"/terraform-releases.json", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	response, err := GetVersionList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(response) != 1 || response[0] != "1.4.6" {
+		t.Errorf("Expected [1.4.6], got %v", response)
+	}
+}
+
+func TestSetUrlTemplates(t *testing.T) {
+	defer SetUrlTemplates("", "", "")
+	cases := []struct {
+		name, index, download, checksum, want string
+	}{
+		{"all placeholders", "", "https://mirror/{version}/terraform{suffix}", "https://mirror/{version}/{os}-{arch}.sha256", ""},
+		{"index only", "https://mirror/terraform/releases.json", "", "", ""},
+		{"unknown placeholder", "", "https://mirror/{release}/terraform.zip", "",
+			`invalid download URL template "https://mirror/{release}/terraform.zip": unknown placeholder {release}, ` +
+				"expected {version}, {os}, {arch} or {suffix}"},
+		{"invalid scheme", "", "", "s3://bucket/{version}/SHA256SUMS",
+			`invalid checksum URL template "s3://bucket/{version}/SHA256SUMS": expected an http://, https:// or file:// URL`},
+	}
+
+	for _, c := range cases {
+		t.Run("test url templates: "+c.name, func(t *testing.T) {
+			err := SetUrlTemplates(c.index, c.download, c.checksum)
+			if c.want == "" && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+			if c.want != "" && (err == nil || err.Error() != c.want) {
+				t.Errorf("Expected %q, got %v", c.want, err)
+			}
+		})
+	}
+}
+
+func TestExpandUrlTemplate(t *testing.T) {
+	got := expandUrlTemplate("https://mirror/{version}/terraform-{os}-{arch}.zip#{suffix}", "1.0.1", "_darwin_amd64.zip")
+	want := "https://mirror/1.0.1/terraform-darwin-amd64.zip#_darwin_amd64.zip"
+	if got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
 }